@@ -9,6 +9,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Position is where the node starts in the source
+	Position() token.Position
 }
 
 type Statement interface {
@@ -33,6 +35,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Position() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Position()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -47,6 +56,8 @@ type JeffStatement struct {
 	Token token.Token
 	Name  *Indentifier
 	Value Expression
+	// Doc is the # comment directly above the statement. Empty if there isn't one
+	Doc string
 }
 
 func (l *JeffStatement) statementNode() {}
@@ -55,6 +66,10 @@ func (l *JeffStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *JeffStatement) Position() token.Position {
+	return l.Token.Position
+}
+
 func (l *JeffStatement) String() string {
 
 	var out bytes.Buffer
@@ -75,6 +90,10 @@ func (l *JeffStatement) String() string {
 type Indentifier struct {
 	Token token.Token
 	Value string
+	// Where the variable lives, filled in by the resolver. Depth is how many functions out it was
+	// declared and Slot is its place in that function's locals, or -1 for the top level
+	Depth int
+	Slot  int
 }
 
 func (i *Indentifier) expressionNode() {}
@@ -83,6 +102,10 @@ func (i *Indentifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Indentifier) Position() token.Position {
+	return i.Token.Position
+}
+
 func (i *Indentifier) String() string {
 	return i.Value
 }
@@ -98,6 +121,10 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Position() token.Position {
+	return rs.Token.Position
+}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -123,6 +150,10 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Position() token.Position {
+	return es.Token.Position
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -134,6 +165,8 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	// Object the evaluator made for the literal the first time it ran, reused after that
+	Object interface{}
 }
 
 func (il *IntegerLiteral) expressionNode() {}
@@ -142,10 +175,35 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Position() token.Position {
+	return il.Token.Position
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+	// Object the evaluator made for the literal the first time it ran, reused after that
+	Object interface{}
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Position() token.Position {
+	return fl.Token.Position
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -158,6 +216,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Position() token.Position {
+	return pe.Token.Position
+}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -186,6 +248,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Position() token.Position {
+	return ie.Token.Position
+}
+
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -207,8 +273,22 @@ func (b *Boolean) expressionNode() {}
 
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 
+func (b *Boolean) Position() token.Position { return b.Token.Position }
+
 func (b *Boolean) String() string { return b.Token.Literal }
 
+type Null struct {
+	Token token.Token
+}
+
+func (n *Null) expressionNode() {}
+
+func (n *Null) TokenLiteral() string { return n.Token.Literal }
+
+func (n *Null) Position() token.Position { return n.Token.Position }
+
+func (n *Null) String() string { return n.Token.Literal }
+
 // If statemnets:
 // if (condition) {consequence} else {alternative}
 type IfExpression struct {
@@ -222,6 +302,8 @@ func (ie *IfExpression) expressionNode() {}
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *IfExpression) Position() token.Position { return ie.Token.Position }
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -239,10 +321,18 @@ func (ie *IfExpression) String() string {
 }
 
 // FunctionLiterals are in the format fn(Parameters) { Body }
+// Parameters can have defaults and the last one can collect extra arguments. e.g. fn(x, y is 10, ...rest)
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Indentifier
-	Body       *BlockStatement
+	// Default value of each parameter, lined up with Parameters. nil for parameters without one
+	Defaults []Expression
+	// Rest collects any extra arguments into an array. nil if there isn't one
+	Rest *Indentifier
+	Body *BlockStatement
+	// Names of the function's local variables in slot order, starting with the parameters.
+	// Filled in by the resolver
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -251,14 +341,26 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Position() token.Position {
+	return fl.Token.Position
+}
+
 func (fl *FunctionLiteral) String() string {
 
 	var out bytes.Buffer
 
 	params := []string{}
 
-	for _, param := range fl.Parameters {
-		params = append(params, param.String())
+	for i, param := range fl.Parameters {
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			params = append(params, param.String()+" is "+fl.Defaults[i].String())
+		} else {
+			params = append(params, param.String())
+		}
+	}
+
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -270,16 +372,46 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// StructLiterals declare a record type with named fields
+// e.g. struct { x, y }
+type StructLiteral struct {
+	Token  token.Token
+	Fields []*Indentifier
+}
+
+func (sl *StructLiteral) expressionNode() {}
+
+func (sl *StructLiteral) TokenLiteral() string {
+	return sl.Token.Literal
+}
+
+func (sl *StructLiteral) Position() token.Position {
+	return sl.Token.Position
+}
+
+func (sl *StructLiteral) String() string {
+	fields := []string{}
+
+	for _, field := range sl.Fields {
+		fields = append(fields, field.String())
+	}
+
+	return sl.TokenLiteral() + " { " + strings.Join(fields, ", ") + " }"
+}
+
 type CallExpression struct {
 	Token     token.Token // (
 	Function  Expression  // Function literal or identfier
 	Arguments []Expression
+	Tail      bool // Set by the parser when the call's result is returned straight away
 }
 
 func (ce *CallExpression) expressionNode() {}
 
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 
+func (ce *CallExpression) Position() token.Position { return ce.Token.Position }
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -309,6 +441,10 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Position() token.Position {
+	return bs.Token.Position
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -322,6 +458,8 @@ func (bs *BlockStatement) String() string {
 type StringLiteral struct {
 	Token token.Token
 	Value string
+	// Object the evaluator made for the literal the first time it ran, reused after that
+	Object interface{}
 }
 
 func (sl *StringLiteral) expressionNode() {}
@@ -330,6 +468,368 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+func (sl *StringLiteral) Position() token.Position {
+	return sl.Token.Position
+}
+
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
+
+// ArrayLiterals are a list of expressions wrapped in []
+// e.g. [1, 2 * 2, add(1, 2)]
+type ArrayLiteral struct {
+	Token    token.Token // [
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+
+func (al *ArrayLiteral) TokenLiteral() string {
+	return al.Token.Literal
+}
+
+func (al *ArrayLiteral) Position() token.Position {
+	return al.Token.Position
+}
+
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+
+	for _, element := range al.Elements {
+		elements = append(elements, element.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// IndexExpressions access a single element of the expression on the left
+// e.g. myArray[1] or [1, 2, 3][0]
+type IndexExpression struct {
+	Token token.Token // [
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode() {}
+
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *IndexExpression) Position() token.Position {
+	return ie.Token.Position
+}
+
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// MemberExpressions get a top level binding out of a module
+// e.g. utils.add
+type MemberExpression struct {
+	Token    token.Token // .
+	Left     Expression
+	Property *Indentifier
+}
+
+func (me *MemberExpression) expressionNode() {}
+
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) Position() token.Position {
+	return me.Token.Position
+}
+
+func (me *MemberExpression) String() string {
+	return me.Left.String() + "." + me.Property.String()
+}
+
+// HashLiterals are key value pairs wrapped in {}
+// e.g. {"name": "jeff", 1: right}
+type HashLiteral struct {
+	Token token.Token // {
+	// In the order they were written
+	Pairs []HashPair
+}
+
+// HashPair is a key and its value in a hash literal
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+
+func (hl *HashLiteral) TokenLiteral() string {
+	return hl.Token.Literal
+}
+
+func (hl *HashLiteral) Position() token.Position {
+	return hl.Token.Position
+}
+
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// While statements repeat the body as long as the condition is truthy
+// while (condition) { body }
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Position() token.Position {
+	return ws.Token.Position
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// Break statements stop the closest while loop
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Position() token.Position {
+	return bs.Token.Position
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+// Import statements load another .jeff file as a module
+// e.g. import "utils.jeff" binds the module to utils
+type ImportStatement struct {
+	Token token.Token
+	Path  string
+	// Name the module is bound to. The file name without its directory or .jeff
+	Name *Indentifier
+}
+
+func (is *ImportStatement) statementNode() {}
+
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) Position() token.Position {
+	return is.Token.Position
+}
+
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " \"" + is.Path + "\";"
+}
+
+// Continue statements skip to the next iteration of the closest while loop
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Position() token.Position {
+	return cs.Token.Position
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
+// Assign expressions update a variable that has already been declared with jeff's
+// e.g. x is x + 1
+// FieldAssignExpressions update a field of a struct
+// e.g. p.x is 3
+type FieldAssignExpression struct {
+	Token  token.Token // is
+	Target *MemberExpression
+	Value  Expression
+}
+
+func (fa *FieldAssignExpression) expressionNode() {}
+
+func (fa *FieldAssignExpression) TokenLiteral() string {
+	return fa.Token.Literal
+}
+
+func (fa *FieldAssignExpression) Position() token.Position {
+	return fa.Token.Position
+}
+
+func (fa *FieldAssignExpression) String() string {
+	return "(" + fa.Target.String() + " is " + fa.Value.String() + ")"
+}
+
+type AssignExpression struct {
+	Token token.Token // is
+	Name  *Indentifier
+	Value Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Position() token.Position {
+	return ae.Token.Position
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" is ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Try expressions run the handler if the block errors
+// try { block } catch (param) { handler }
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Indentifier
+	Handler *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TryExpression) Position() token.Position {
+	return te.Token.Position
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try")
+	out.WriteString(te.Block.String())
+	out.WriteString("catch(")
+	out.WriteString(te.Param.String())
+	out.WriteString(")")
+	out.WriteString(te.Handler.String())
+
+	return out.String()
+}
+
+// SliceExpressions take a range of elements from the expression on the left.
+// Start and End are optional. e.g. myArray[1:3], "jeff"[:2], myArray[1:]
+type SliceExpression struct {
+	Token token.Token // [
+	Left  Expression
+	Start Expression
+	End   Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SliceExpression) Position() token.Position {
+	return se.Token.Position
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// InterpolatedStrings are strings with expressions embedded in them.
+// Parts are the plain string pieces and the expressions, in order.
+// e.g. "hello ${name}" has the parts "hello " and name
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {}
+
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *InterpolatedString) Position() token.Position {
+	return is.Token.Position
+}
+
+func (is *InterpolatedString) String() string {
+	return is.Token.Literal
+}
@@ -0,0 +1,88 @@
+package ast
+
+// Walk calls visit for the node and everything inside it, depth first.
+// Children are skipped when visit returns false
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			Walk(statement, visit)
+		}
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			Walk(statement, visit)
+		}
+	case *ExpressionStatement:
+		walkExpression(node.Expression, visit)
+	case *ReturnStatement:
+		walkExpression(node.ReturnValue, visit)
+	case *JeffStatement:
+		walkExpression(node.Value, visit)
+	case *WhileStatement:
+		walkExpression(node.Condition, visit)
+		Walk(node.Body, visit)
+	case *FunctionLiteral:
+		for _, def := range node.Defaults {
+			walkExpression(def, visit)
+		}
+		Walk(node.Body, visit)
+	case *CallExpression:
+		walkExpression(node.Function, visit)
+		for _, arg := range node.Arguments {
+			walkExpression(arg, visit)
+		}
+	case *AssignExpression:
+		Walk(node.Name, visit)
+		walkExpression(node.Value, visit)
+	case *FieldAssignExpression:
+		Walk(node.Target, visit)
+		walkExpression(node.Value, visit)
+	case *InfixExpression:
+		walkExpression(node.Left, visit)
+		walkExpression(node.Right, visit)
+	case *PrefixExpression:
+		walkExpression(node.Right, visit)
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			walkExpression(element, visit)
+		}
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			walkExpression(pair.Key, visit)
+			walkExpression(pair.Value, visit)
+		}
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			walkExpression(part, visit)
+		}
+	case *IndexExpression:
+		walkExpression(node.Left, visit)
+		walkExpression(node.Index, visit)
+	case *SliceExpression:
+		walkExpression(node.Left, visit)
+		walkExpression(node.Start, visit)
+		walkExpression(node.End, visit)
+	case *MemberExpression:
+		walkExpression(node.Left, visit)
+	case *IfExpression:
+		walkExpression(node.Condition, visit)
+		Walk(node.Consequence, visit)
+		if node.Alternative != nil {
+			Walk(node.Alternative, visit)
+		}
+	case *TryExpression:
+		Walk(node.Block, visit)
+		Walk(node.Handler, visit)
+	}
+}
+
+// walkExpression walks an expression that may be missing
+func walkExpression(node Expression, visit func(Node) bool) {
+	if node != nil {
+		Walk(node, visit)
+	}
+}
@@ -0,0 +1,303 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions are a sequence of bytecode instructions.
+// Each is a one byte Opcode followed by its operands
+type Instructions []byte
+
+// String disassembles the instructions, one per line with its offset. e.g.
+//
+//	0000 OpConstant 0
+//	0003 OpConstant 1
+//	0006 OpAdd
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	out := def.Name
+	for _, operand := range operands {
+		out += fmt.Sprintf(" %d", operand)
+	}
+	return out
+}
+
+type Opcode byte
+
+const (
+	// Push a value from the constant pool
+	OpConstant Opcode = iota
+	OpPop
+	OpDup
+	OpTrue
+	OpFalse
+	OpNull
+
+	// Infix operators. Pop the right then the left side and push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpFloorDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+
+	// Prefix operators
+	OpMinus
+	OpBang
+	// Replace the top of the stack with right or huang depending on if it is truthy
+	OpTruthy
+
+	OpJump
+	// Pop the top of the stack and jump if it isn't truthy
+	OpJumpNotTruthy
+	// Jump if the top of the stack isn't null, leaving it there. Otherwise pop it
+	OpJumpNotNull
+	// Jump if the local slot has been given a value. Used to skip default parameters
+	OpJumpIfSet
+
+	// Globals are looked up by name, the operand is the constant holding the name
+	OpGetGlobal
+	// Pop a value and declare it as a global
+	OpDefineGlobal
+	// Pop a value and update an existing global
+	OpSetGlobal
+	// Pop a value into a global for a catch parameter, and push the value it hides (nil if none)
+	OpCatchGlobal
+	// Put back the global a catch parameter hid. Pops the handler's result and the hidden value,
+	// then pushes the result again
+	OpRestoreGlobal
+
+	OpGetLocal
+	// Pop a value and declare it in a local slot
+	OpDefineLocal
+	// Pop a value and update a local that has been declared
+	OpSetLocal
+
+	// Cells hold locals that are captured by closures, so the closure and the
+	// function that declared them see the same variable
+	OpGetCell
+	OpDefineCell
+	OpSetCell
+	// Put an empty cell in a local slot
+	OpNewCell
+	// Move the value in a local slot into a new cell
+	OpBox
+	// Push the cell in a local slot, so it can be captured by a closure
+	OpLocalCell
+
+	// Free variables are the cells captured by the current closure
+	OpGetFree
+	OpSetFree
+	OpFreeCell
+
+	// Create a closure from a compiled function constant and the given number of cells on the stack
+	OpClosure
+	OpCall
+	// Calls a function in tail position, reusing the current frame
+	OpTailCall
+	OpReturnValue
+
+	OpArray
+	OpHash
+	OpIndex
+	// The operand says which bounds are on the stack. 1 for the start, 2 for the end
+	OpSlice
+	// Join the given number of values into a string
+	OpInterpolate
+	OpMember
+	// Set a field of a struct. Operands are the field name and the whole target, for errors
+	OpSetField
+	// Create a new struct type with the fields of a struct type constant
+	OpStruct
+	// Name the function or struct on top of the stack, if it hasn't got a name yet.
+	// Operands are the name and doc comment constants
+	OpName
+	// Push a module. Operands are the path and name constants
+	OpImport
+
+	// Start a try block. Errors before the matching OpPopTry jump to the operand
+	// with the stack put back how it was and the error pushed as a hash
+	OpSetupTry
+	OpPopTry
+	// Raise an error with the message constant
+	OpFail
+)
+
+// Definition gives an opcode a readable name and says how many bytes each operand uses
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpDup:      {"OpDup", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpFloorDiv:     {"OpFloorDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpTruthy: {"OpTruthy", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},
+	OpJumpIfSet:     {"OpJumpIfSet", []int{1, 2}},
+
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpDefineGlobal:  {"OpDefineGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpCatchGlobal:   {"OpCatchGlobal", []int{2}},
+	OpRestoreGlobal: {"OpRestoreGlobal", []int{2}},
+
+	OpGetLocal:    {"OpGetLocal", []int{1}},
+	OpDefineLocal: {"OpDefineLocal", []int{1}},
+	OpSetLocal:    {"OpSetLocal", []int{1}},
+
+	OpGetCell:    {"OpGetCell", []int{1}},
+	OpDefineCell: {"OpDefineCell", []int{1}},
+	OpSetCell:    {"OpSetCell", []int{1}},
+	OpNewCell:    {"OpNewCell", []int{1}},
+	OpBox:        {"OpBox", []int{1}},
+	OpLocalCell:  {"OpLocalCell", []int{1}},
+
+	OpGetFree:  {"OpGetFree", []int{1}},
+	OpSetFree:  {"OpSetFree", []int{1}},
+	OpFreeCell: {"OpFreeCell", []int{1}},
+
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpCall:        {"OpCall", []int{1}},
+	OpTailCall:    {"OpTailCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpSlice:       {"OpSlice", []int{1}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpMember:      {"OpMember", []int{2}},
+	OpSetField:    {"OpSetField", []int{2, 2}},
+	OpStruct:      {"OpStruct", []int{2}},
+	OpName:        {"OpName", []int{2, 2}},
+	OpImport:      {"OpImport", []int{2, 2}},
+
+	OpSetupTry: {"OpSetupTry", []int{2}},
+	OpPopTry:   {"OpPopTry", []int{}},
+	OpFail:     {"OpFail", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction. Operands are written big endian
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, width := range def.OperandWidths {
+		instructionLen += width
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, operand := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(operand))
+		case 1:
+			instruction[offset] = byte(operand)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, the opposite of Make.
+// Also returns how many bytes were read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
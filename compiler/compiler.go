@@ -0,0 +1,847 @@
+package compiler
+
+import (
+	"fmt"
+	"jeff/ast"
+	"jeff/code"
+	"jeff/object"
+	"jeff/token"
+	"math"
+)
+
+// Compiler lowers an AST to bytecode for the vm. The result runs the same as
+// evaluating the AST with the evaluator
+type Compiler struct {
+	constants []object.Object
+	// Index of each string, integer and float in the constant pool, so each is only stored once
+	strings  map[string]int
+	integers map[int64]int
+	floats   map[uint64]int
+
+	// Innermost function being compiled last. The first scope is the top level of the program
+	scopes []*scope
+
+	// Position of the node being compiled, recorded against each instruction emitted for it
+	position token.Position
+
+	// First operand that was too big for its instruction. Returned once compiling is done
+	err error
+}
+
+// scope holds the instructions of one function as they are compiled
+type scope struct {
+	instructions  code.Instructions
+	positions     map[int]token.Position
+	callPositions map[int]token.Position
+
+	// nil at the top level, where every variable is global
+	symbols *SymbolTable
+
+	// Enclosing while loops, innermost last
+	loops []*loop
+	// Number of try blocks the code being compiled is in
+	tries int
+	// Number of values the code being compiled leaves on the stack
+	depth int
+}
+
+// loop tracks a while loop so break and continue can jump out of it
+type loop struct {
+	start  int
+	breaks []int
+	// tries and depth when the loop started, so break and continue can put them back
+	tries int
+	depth int
+}
+
+// Error is a program the compiler can't compile
+type Error struct {
+	Message string
+	// Position of the node that couldn't be compiled
+	Position token.Position
+}
+
+func (e *Error) Error() string {
+	return e.Position.String() + ": " + e.Message
+}
+
+func newError(position token.Position, format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Position: position}
+}
+
+// Compile lowers a program to a function the vm can run
+func Compile(program *ast.Program) (*object.CompiledFunction, error) {
+	c := New()
+
+	if err := c.compileProgram(program); err != nil {
+		return nil, err
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	main := c.leaveScope(nil, 0)
+
+	// Functions are compiled before the constant pool is finished, so they are given it at the end
+	main.Constants = c.constants
+	for _, constant := range c.constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fn.Constants = c.constants
+		}
+	}
+
+	return main, nil
+}
+
+func New() *Compiler {
+	c := &Compiler{strings: map[string]int{}, integers: map[int64]int{}, floats: map[uint64]int{}}
+	c.enterScope(nil)
+	return c
+}
+
+func (c *Compiler) compileProgram(program *ast.Program) error {
+	for i, statement := range program.Statements {
+		produced, err := c.compileStatement(statement)
+		if err != nil {
+			return err
+		}
+
+		// The value of the last statement is the result of the program
+		if produced && i < len(program.Statements)-1 {
+			c.emit(code.OpPop)
+		}
+	}
+
+	return nil
+}
+
+// compileBlock compiles the statements of a block. If the value is kept the block leaves
+// the value of its last statement on the stack, or null if it doesn't have one
+func (c *Compiler) compileBlock(block *ast.BlockStatement, keepValue bool) error {
+	for i, statement := range block.Statements {
+		produced, err := c.compileStatement(statement)
+		if err != nil {
+			return err
+		}
+
+		last := i == len(block.Statements)-1
+		if produced && (!keepValue || !last) {
+			c.emit(code.OpPop)
+		}
+		if !produced && keepValue && last {
+			c.emit(code.OpNull)
+		}
+	}
+
+	if keepValue && len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// compileStatement compiles a statement and reports if it left a value on the stack
+func (c *Compiler) compileStatement(statement ast.Statement) (bool, error) {
+	// Programs with parser errors can have statements missing
+	if statement == nil {
+		return false, newError(c.position, "missing statement")
+	}
+
+	previous := c.position
+	c.position = statement.Position()
+	defer func() { c.position = previous }()
+
+	s := c.scope()
+
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		if statement.Expression == nil {
+			return false, nil
+		}
+		return true, c.compile(statement.Expression)
+
+	case *ast.ReturnStatement:
+		if statement.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compile(statement.ReturnValue); err != nil {
+			return false, err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.JeffStatement:
+		if err := c.compile(statement.Value); err != nil {
+			return false, err
+		}
+		c.emit(code.OpName, c.addString(statement.Name.Value), c.addString(statement.Doc))
+		c.define(statement.Name.Value)
+
+	case *ast.ImportStatement:
+		c.emit(code.OpImport, c.addString(statement.Path), c.addString(statement.Name.Value))
+		c.define(statement.Name.Value)
+
+	case *ast.WhileStatement:
+		return true, c.compileWhileStatement(statement)
+
+	case *ast.BreakStatement:
+		if len(s.loops) == 0 {
+			c.emit(code.OpFail, c.addString("break outside of loop"))
+			return false, nil
+		}
+		loop := s.loops[len(s.loops)-1]
+		loop.breaks = append(loop.breaks, c.leaveLoop(loop))
+
+	case *ast.ContinueStatement:
+		if len(s.loops) == 0 {
+			c.emit(code.OpFail, c.addString("continue outside of loop"))
+			return false, nil
+		}
+		loop := s.loops[len(s.loops)-1]
+		c.changeOperand(c.leaveLoop(loop), loop.start)
+
+	default:
+		return false, newError(c.position, "can't compile %T", statement)
+	}
+
+	return false, nil
+}
+
+// leaveLoop emits a jump out of the loop, first dropping anything the loop body left on the stack
+// and any try blocks it is in. Returns the position of the jump so it can be given a target
+func (c *Compiler) leaveLoop(loop *loop) int {
+	s := c.scope()
+	depth := s.depth
+
+	for i := s.depth; i > loop.depth; i-- {
+		c.emit(code.OpPop)
+	}
+	for i := s.tries; i > loop.tries; i-- {
+		c.emit(code.OpPopTry)
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	// Code after the jump is never run, but is compiled as if it could be
+	s.depth = depth
+	return jump
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	s := c.scope()
+	start := len(s.instructions)
+
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	loop := &loop{start: start, tries: s.tries, depth: s.depth}
+	s.loops = append(s.loops, loop)
+
+	if err := c.compileBlock(node.Body, false); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	s.loops = s.loops[:len(s.loops)-1]
+
+	end := len(s.instructions)
+	c.changeOperand(exit, end)
+	for _, jump := range loop.breaks {
+		c.changeOperand(jump, end)
+	}
+
+	// Loops are statements but still have a value, like in the evaluator
+	c.emit(code.OpNull)
+	return nil
+}
+
+func (c *Compiler) compile(node ast.Expression) error {
+	// Programs with parser errors can have expressions missing
+	if node == nil {
+		return newError(c.position, "missing expression")
+	}
+
+	previous := c.position
+	c.position = node.Position()
+	defer func() { c.position = previous }()
+
+	s := c.scope()
+
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addString(node.Value))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.Null:
+		c.emit(code.OpNull)
+
+	case *ast.Indentifier:
+		c.load(node.Value)
+
+	case *ast.AssignExpression:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpDup)
+		c.assign(node.Name.Value)
+
+	case *ast.PrefixExpression:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return newError(c.position, "unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+
+	case *ast.IfExpression:
+		if err := c.compile(node.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBlock(node.Consequence, true); err != nil {
+			return err
+		}
+		jump := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthy, len(s.instructions))
+		s.depth--
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBlock(node.Alternative, true); err != nil {
+			return err
+		}
+
+		c.changeOperand(jump, len(s.instructions))
+
+	case *ast.TryExpression:
+		setup := c.emit(code.OpSetupTry, 9999)
+
+		s.tries++
+		if err := c.compileBlock(node.Block, true); err != nil {
+			return err
+		}
+		s.tries--
+
+		c.emit(code.OpPopTry)
+		jump := c.emit(code.OpJump, 9999)
+
+		// The handler starts with the stack put back how it was before the try,
+		// plus the error
+		c.changeOperand(setup, len(s.instructions))
+		if err := c.compileHandler(node); err != nil {
+			return err
+		}
+
+		c.changeOperand(jump, len(s.instructions))
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		if err := c.compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		if len(node.Arguments) > 255 {
+			return newError(node.Position(), "too many arguments")
+		}
+
+		op := code.OpCall
+		if node.Tail {
+			op = code.OpTailCall
+		}
+		call := c.emit(op, len(node.Arguments))
+		s.callPositions[call] = node.Function.Position()
+
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			if err := c.compile(element); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+
+		bounds := 0
+		if node.Start != nil {
+			if err := c.compile(node.Start); err != nil {
+				return err
+			}
+			bounds |= 1
+		}
+		if node.End != nil {
+			if err := c.compile(node.End); err != nil {
+				return err
+			}
+			bounds |= 2
+		}
+		c.emit(code.OpSlice, bounds)
+
+	case *ast.MemberExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addString(node.Property.Value))
+
+	case *ast.FieldAssignExpression:
+		if err := c.compile(node.Target.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetField, c.addString(node.Target.Property.Value), c.addString(node.Target.String()))
+
+	case *ast.StructLiteral:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		c.emit(code.OpStruct, c.addConstant(&object.StructType{Fields: fields}))
+
+	default:
+		return newError(c.position, "can't compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"//": code.OpFloorDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	s := c.scope()
+
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	// and, or and ?? only run the right side if the left side doesn't decide the result
+	switch node.Operator {
+	case "and":
+		jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpTruthy)
+		jump := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthy, len(s.instructions))
+		s.depth--
+		c.emit(code.OpFalse)
+		c.changeOperand(jump, len(s.instructions))
+		return nil
+
+	case "or":
+		jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jump := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthy, len(s.instructions))
+		s.depth--
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpTruthy)
+		c.changeOperand(jump, len(s.instructions))
+		return nil
+
+	case "??":
+		jumpNotNull := c.emit(code.OpJumpNotNull, 9999)
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpNotNull, len(s.instructions))
+		return nil
+	}
+
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+
+	op, ok := infixOperators[node.Operator]
+	if !ok {
+		return newError(c.position, "unknown operator %s", node.Operator)
+	}
+	c.emit(op)
+
+	return nil
+}
+
+// compileFunctionLiteral compiles the function into a constant and emits the instructions to
+// create a closure of it, capturing the cells of the variables it uses from enclosing functions
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	symbols := NewEnclosedSymbolTable(c.scope().symbols, capturedNames(node))
+	c.enterScope(symbols)
+
+	// Parameters take the first slots, in order, followed by the rest parameter
+	for _, param := range node.Parameters {
+		symbols.Reserve(param.Value)
+	}
+	if node.Rest != nil {
+		symbols.Reserve(node.Rest.Value)
+	}
+
+	// Captured locals need their cell from the start, in case a closure is created before they are declared
+	for _, name := range declaredNames(node) {
+		if !symbols.Reserved(name) && symbols.captured[name] {
+			c.emit(code.OpNewCell, symbols.Reserve(name).Index)
+		}
+	}
+
+	// Each parameter can only see the ones before it, so the defaults are filled in one by one
+	required := 0
+	for i, param := range node.Parameters {
+		if i < len(node.Defaults) && node.Defaults[i] != nil {
+			jumpIfSet := c.emit(code.OpJumpIfSet, i, 9999)
+			if err := c.compile(node.Defaults[i]); err != nil {
+				return err
+			}
+			c.emit(code.OpDefineLocal, i)
+			c.changeOperand(jumpIfSet, len(c.scope().instructions))
+		} else {
+			required += 1
+		}
+
+		if symbol := symbols.Define(param.Value); symbol.Boxed {
+			c.emit(code.OpBox, symbol.Index)
+		}
+	}
+	if node.Rest != nil {
+		if symbol := symbols.Define(node.Rest.Value); symbol.Boxed {
+			c.emit(code.OpBox, symbol.Index)
+		}
+	}
+
+	if err := c.compileBlock(node.Body, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	if symbols.NumLocals() > 256 {
+		return newError(node.Position(), "too many local variables")
+	}
+	if len(symbols.FreeSymbols) > 255 {
+		return newError(node.Position(), "too many captured variables")
+	}
+
+	fn := c.leaveScope(node, required)
+
+	for _, free := range symbols.FreeSymbols {
+		if free.Scope == LocalScope {
+			c.emit(code.OpLocalCell, free.Index)
+		} else {
+			c.emit(code.OpFreeCell, free.Index)
+		}
+	}
+
+	c.emit(code.OpClosure, c.addConstant(fn), len(symbols.FreeSymbols))
+	return nil
+}
+
+// compileHandler compiles the catch block of a try, with the error on the stack.
+// The parameter only exists inside the block. In a function it gets a slot of its own,
+// while at the top level the global it hides is put back afterwards
+func (c *Compiler) compileHandler(node *ast.TryExpression) error {
+	symbols := c.scope().symbols
+	if symbols == nil {
+		name := c.addString(node.Param.Value)
+		c.emit(code.OpCatchGlobal, name)
+		if err := c.compileBlock(node.Handler, true); err != nil {
+			return err
+		}
+		c.emit(code.OpRestoreGlobal, name)
+		return nil
+	}
+
+	symbol := symbols.Catch(node.Param.Value)
+	if symbol.Boxed {
+		c.emit(code.OpNewCell, symbol.Index)
+		c.emit(code.OpDefineCell, symbol.Index)
+	} else {
+		c.emit(code.OpDefineLocal, symbol.Index)
+	}
+
+	err := c.compileBlock(node.Handler, true)
+	symbols.EndCatch()
+	return err
+}
+
+// load emits the instruction to push the value of a variable
+func (c *Compiler) load(name string) {
+	symbols := c.scope().symbols
+	if symbols == nil {
+		c.emit(code.OpGetGlobal, c.addString(name))
+		return
+	}
+
+	symbol := symbols.Resolve(name)
+	switch {
+	case symbol.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, c.addString(name))
+	case symbol.Scope == FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	case symbol.Boxed:
+		c.emit(code.OpGetCell, symbol.Index)
+	default:
+		c.emit(code.OpGetLocal, symbol.Index)
+	}
+}
+
+// define emits the instruction to pop a value into a new variable
+func (c *Compiler) define(name string) {
+	symbols := c.scope().symbols
+	if symbols == nil {
+		c.emit(code.OpDefineGlobal, c.addString(name))
+		return
+	}
+
+	reserved := symbols.Reserved(name)
+	symbol := symbols.Define(name)
+	if !symbol.Boxed {
+		c.emit(code.OpDefineLocal, symbol.Index)
+		return
+	}
+
+	if !reserved {
+		c.emit(code.OpNewCell, symbol.Index)
+	}
+	c.emit(code.OpDefineCell, symbol.Index)
+}
+
+// assign emits the instruction to pop a value into an existing variable
+func (c *Compiler) assign(name string) {
+	symbols := c.scope().symbols
+	if symbols == nil {
+		c.emit(code.OpSetGlobal, c.addString(name))
+		return
+	}
+
+	symbol := symbols.Resolve(name)
+	switch {
+	case symbol.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, c.addString(name))
+	case symbol.Scope == FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	case symbol.Boxed:
+		c.emit(code.OpSetCell, symbol.Index)
+	default:
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+func (c *Compiler) scope() *scope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) enterScope(symbols *SymbolTable) {
+	c.scopes = append(c.scopes, &scope{
+		positions:     map[int]token.Position{},
+		callPositions: map[int]token.Position{},
+		symbols:       symbols,
+	})
+}
+
+// leaveScope finishes the innermost function. literal is nil for the top level
+func (c *Compiler) leaveScope(literal *ast.FunctionLiteral, required int) *object.CompiledFunction {
+	s := c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+
+	fn := &object.CompiledFunction{
+		Instructions:  s.instructions,
+		NumRequired:   required,
+		Positions:     s.positions,
+		CallPositions: s.callPositions,
+		Literal:       literal,
+	}
+
+	if s.symbols != nil {
+		fn.NumLocals = s.symbols.NumLocals()
+		fn.LocalNames = s.symbols.names
+		for _, free := range s.symbols.FreeSymbols {
+			fn.FreeNames = append(fn.FreeNames, free.Name)
+		}
+	}
+
+	if literal != nil {
+		fn.NumParameters = len(literal.Parameters)
+		fn.Variadic = literal.Rest != nil
+	}
+
+	return fn
+}
+
+// emit adds an instruction to the current function and returns its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	s := c.scope()
+
+	c.checkOperands(op, operands)
+
+	position := len(s.instructions)
+	s.instructions = append(s.instructions, code.Make(op, operands...)...)
+	s.positions[position] = c.position
+	s.depth += stackEffect(op, operands)
+
+	return position
+}
+
+// changeOperand points the jump at position to target
+func (c *Compiler) changeOperand(position int, target int) {
+	s := c.scope()
+
+	op := code.Opcode(s.instructions[position])
+	def, _ := code.Lookup(byte(op))
+	operands, _ := code.ReadOperands(def, s.instructions[position+1:])
+
+	// The target is always the last operand
+	operands[len(operands)-1] = target
+	c.checkOperands(op, operands)
+	copy(s.instructions[position:], code.Make(op, operands...))
+}
+
+// checkOperands records an error if a two byte operand is too big, as Make would wrap it around.
+// One byte operands are checked where they are worked out
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, _ := code.Lookup(byte(op))
+
+	for i, operand := range operands {
+		if def.OperandWidths[i] != 2 || operand <= math.MaxUint16 || c.err != nil {
+			continue
+		}
+
+		switch op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNotNull, code.OpJumpIfSet, code.OpSetupTry:
+			c.err = newError(c.position, "too much code to jump over")
+		case code.OpArray, code.OpHash, code.OpInterpolate:
+			c.err = newError(c.position, "too many elements")
+		default:
+			c.err = newError(c.position, "too many constants")
+		}
+	}
+}
+
+// addConstant adds a value to the constant pool. Integers and floats that are already there are reused
+func (c *Compiler) addConstant(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		if index, ok := c.integers[obj.Value]; ok {
+			return index
+		}
+		c.integers[obj.Value] = len(c.constants)
+	case *object.Float:
+		// Keyed by the bits so 0.0 and -0.0 stay apart
+		bits := math.Float64bits(obj.Value)
+		if index, ok := c.floats[bits]; ok {
+			return index
+		}
+		c.floats[bits] = len(c.constants)
+	}
+
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// addString adds a string to the constant pool, reusing it if it is already there
+func (c *Compiler) addString(value string) int {
+	if index, ok := c.strings[value]; ok {
+		return index
+	}
+
+	index := c.addConstant(&object.String{Value: value})
+	c.strings[value] = index
+	return index
+}
+
+// stackEffect is how many values an instruction adds to the stack, or removes if negative.
+// Conditional jumps count as not jumping
+func stackEffect(op code.Opcode, operands []int) int {
+	switch op {
+	case code.OpConstant, code.OpDup, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetCell, code.OpGetFree,
+		code.OpLocalCell, code.OpFreeCell, code.OpStruct, code.OpImport:
+		return 1
+	case code.OpPop, code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow, code.OpFloorDiv,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan, code.OpGreaterEqual, code.OpLessEqual,
+		code.OpJumpNotTruthy, code.OpJumpNotNull, code.OpDefineGlobal, code.OpSetGlobal, code.OpRestoreGlobal, code.OpDefineLocal,
+		code.OpSetLocal, code.OpDefineCell, code.OpSetCell, code.OpSetFree, code.OpIndex, code.OpSetField, code.OpReturnValue:
+		return -1
+	case code.OpClosure:
+		return 1 - operands[1]
+	case code.OpCall, code.OpTailCall:
+		return -operands[0]
+	case code.OpArray, code.OpInterpolate:
+		return 1 - operands[0]
+	case code.OpHash:
+		return 1 - 2*operands[0]
+	case code.OpSlice:
+		return -(operands[0] & 1) - (operands[0] >> 1 & 1)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,297 @@
+package compiler
+
+import (
+	"fmt"
+	"jeff/ast"
+	"jeff/code"
+	"jeff/lexer"
+	"jeff/object"
+	"jeff/parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "1 + 2; 3",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+			},
+		},
+		{
+			input: "-1 <= 2 ** 3",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPow),
+				code.Make(code.OpLessEqual),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "if (right) { 1 } else { 2 }",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input: "if (right) { 1 }",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+			},
+		},
+		{
+			input: "huang and right",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpNotTruthy, 9),
+				code.Make(code.OpTrue),
+				code.Make(code.OpTruthy),
+				code.Make(code.OpJump, 10),
+				code.Make(code.OpFalse),
+			},
+		},
+		{
+			input: "null ?? 1",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpNull),
+				code.Make(code.OpJumpNotNull, 7),
+				code.Make(code.OpConstant, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "jeff's x is 1; x is x + 1",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpName, 1, 2),
+				code.Make(code.OpDefineGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpDup),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// break drops the value of the if it is in before leaving the loop
+			input: "while (right) { 1 + if (right) { break } else { 2 } }",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 27),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 19),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 27),
+				code.Make(code.OpNull),
+				code.Make(code.OpJump, 22),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+			},
+		},
+		{
+			input: "while (right) { try { continue } catch (e) { e } }",
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 29),
+				code.Make(code.OpSetupTry, 16),
+				code.Make(code.OpPopTry),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPopTry),
+				code.Make(code.OpJump, 25),
+				code.Make(code.OpCatchGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpRestoreGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	input := "fn(a, b is 2) { jeff's c is a; fn() { c + b } }"
+
+	main := compile(t, input)
+	testInstructions(t, []code.Instructions{
+		code.Make(code.OpClosure, 4, 0),
+	}, main.Instructions)
+
+	// c and b are captured by the inner function, so they are kept in cells
+	outer := main.Constants[4].(*object.CompiledFunction)
+	testInstructions(t, []code.Instructions{
+		code.Make(code.OpNewCell, 2),
+		code.Make(code.OpJumpIfSet, 1, 11),
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpDefineLocal, 1),
+		code.Make(code.OpBox, 1),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpName, 1, 2),
+		code.Make(code.OpDefineCell, 2),
+		code.Make(code.OpLocalCell, 2),
+		code.Make(code.OpLocalCell, 1),
+		code.Make(code.OpClosure, 3, 2),
+		code.Make(code.OpReturnValue),
+	}, outer.Instructions)
+
+	if outer.NumLocals != 3 || outer.NumParameters != 2 || outer.NumRequired != 1 {
+		t.Errorf("wrong slots. want locals=3 parameters=2 required=1, got locals=%d parameters=%d required=%d",
+			outer.NumLocals, outer.NumParameters, outer.NumRequired)
+	}
+
+	inner := main.Constants[3].(*object.CompiledFunction)
+	testInstructions(t, []code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetFree, 1),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	}, inner.Instructions)
+}
+
+func TestPositions(t *testing.T) {
+	main := compile(t, "jeff's f is fn() { 1 };\n  f(1 + huang)")
+
+	// The call is given the position of its (, and the function being called for tracebacks
+	call := len(main.Instructions) - 2
+	if code.Opcode(main.Instructions[call]) != code.OpCall {
+		t.Fatalf("expected OpCall at %d, got\n%s", call, main.Instructions)
+	}
+	if position := main.Positions[call]; position.Line != 2 || position.Column != 4 {
+		t.Errorf("call position wrong, expected=2:4, got=%d:%d", position.Line, position.Column)
+	}
+	if position := main.CallPositions[call]; position.Line != 2 || position.Column != 3 {
+		t.Errorf("callee position wrong, expected=2:3, got=%d:%d", position.Line, position.Column)
+	}
+
+	add := call - 1
+	if position := main.Positions[add]; position.Line != 2 || position.Column != 7 {
+		t.Errorf("add position wrong, expected=2:7, got=%d:%d", position.Line, position.Column)
+	}
+}
+
+func TestConstantsAreReused(t *testing.T) {
+	main := compile(t, `jeff's x is 1 + 1.5; x + 1 + 1.5 + "x"`)
+
+	// 1, 1.5, then the strings x and the empty doc
+	if len(main.Constants) != 4 {
+		t.Errorf("wrong number of constants. expected=4, got=%d (%v)", len(main.Constants), main.Constants)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	elements := make([]string, 70000)
+	for i := range elements {
+		elements[i] = fmt.Sprint(i)
+	}
+	statements := strings.Repeat("1; ", 20000)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// The first constant that doesn't fit is 65536
+		{"[" + strings.Join(elements[:66000], ", ") + "]", fmt.Sprintf("1:%d: too many constants", len(strings.Join(elements[:65536], ", "))+4)},
+		{"[" + strings.Repeat("1, ", 70000) + "1]", "1:1: too many elements"},
+		{"if (right) { " + statements + "}", "1:1: too much code to jump over"},
+		// Programs with parser errors
+		{"fn(a) { a + }", "1:11: missing expression"},
+		{"jeff's x is ; x", "1:1: missing expression"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(parse(tt.input))
+		if err == nil {
+			t.Errorf("expected an error for %.20q...", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, testCase := range tests {
+		main := compile(t, testCase.input)
+		testInstructions(t, testCase.expectedInstructions, main.Instructions)
+	}
+}
+
+func compile(t *testing.T, input string) *object.CompiledFunction {
+	t.Helper()
+
+	main, err := Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return main
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testInstructions(t *testing.T, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != actual.String() {
+		t.Errorf("wrong instructions.\nwant=\n%s\ngot=\n%s", concatted, actual)
+	}
+}
@@ -0,0 +1,151 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	// Globals are looked up by name in the environment the program runs in
+	GlobalScope SymbolScope = "GLOBAL"
+	// Locals live in a slot of the function's stack frame
+	LocalScope SymbolScope = "LOCAL"
+	// Free variables are locals of an enclosing function captured by a closure
+	FreeScope SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Boxed locals are kept in a cell because a closure captures them
+	Boxed bool
+}
+
+// SymbolTable tracks the variables of one function. The top level of a program has no
+// symbol table, every variable there is global
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]*Symbol
+	free  map[string]*Symbol
+	// Locals that have a slot but haven't been declared yet, so they can't be used by
+	// the function itself. Closures created inside the function can still capture them,
+	// as they usually run after the variable is declared
+	hidden map[string]bool
+	// Names used inside closures created in this function. Locals with these names are boxed
+	captured map[string]bool
+	// Catch parameters of the handlers being compiled, innermost last. Each has a slot of its
+	// own, so it hides a local with the same name rather than overwriting it
+	catches []*Symbol
+
+	// Name of each local slot
+	names       []string
+	FreeSymbols []Symbol
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	return &SymbolTable{
+		Outer:    outer,
+		store:    map[string]*Symbol{},
+		free:     map[string]*Symbol{},
+		hidden:   map[string]bool{},
+		captured: captured,
+	}
+}
+
+// Reserve gives a local a new slot without declaring it yet
+func (s *SymbolTable) Reserve(name string) Symbol {
+	symbol := &Symbol{Name: name, Scope: LocalScope, Index: len(s.names), Boxed: s.captured[name]}
+	s.names = append(s.names, name)
+	s.store[name] = symbol
+	s.hidden[name] = true
+	return *symbol
+}
+
+// Define declares a local. A local with the same name reuses its slot
+func (s *SymbolTable) Define(name string) Symbol {
+	if _, ok := s.store[name]; !ok {
+		s.Reserve(name)
+	}
+
+	delete(s.hidden, name)
+	return *s.store[name]
+}
+
+// Catch gives a catch parameter a new slot. It's used in place of anything else
+// with the name until EndCatch
+func (s *SymbolTable) Catch(name string) Symbol {
+	symbol := &Symbol{Name: name, Scope: LocalScope, Index: len(s.names), Boxed: s.captured[name]}
+	s.names = append(s.names, name)
+	s.catches = append(s.catches, symbol)
+	return *symbol
+}
+
+// EndCatch ends the innermost catch parameter once its handler is compiled
+func (s *SymbolTable) EndCatch() {
+	s.catches = s.catches[:len(s.catches)-1]
+}
+
+// catch finds the innermost catch parameter with the name
+func (s *SymbolTable) catch(name string) (Symbol, bool) {
+	for i := len(s.catches) - 1; i >= 0; i-- {
+		if s.catches[i].Name == name {
+			return *s.catches[i], true
+		}
+	}
+	return Symbol{}, false
+}
+
+// Reserved reports whether the name has a slot, declared or not
+func (s *SymbolTable) Reserved(name string) bool {
+	_, ok := s.store[name]
+	return ok
+}
+
+// Resolve finds the variable a name refers to inside this function
+func (s *SymbolTable) Resolve(name string) Symbol {
+	if symbol, ok := s.catch(name); ok {
+		return symbol
+	}
+	if symbol, ok := s.store[name]; ok && !s.hidden[name] {
+		return *symbol
+	}
+
+	return s.resolveFree(name)
+}
+
+// resolveCaptured finds the variable a name refers to for a closure created inside this function
+func (s *SymbolTable) resolveCaptured(name string) Symbol {
+	if symbol, ok := s.catch(name); ok {
+		return symbol
+	}
+	if symbol, ok := s.store[name]; ok {
+		return *symbol
+	}
+
+	return s.resolveFree(name)
+}
+
+// resolveFree looks for the name in the enclosing functions, capturing it as a free variable if found
+func (s *SymbolTable) resolveFree(name string) Symbol {
+	if symbol, ok := s.free[name]; ok {
+		return *symbol
+	}
+
+	if s.Outer == nil {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+
+	original := s.Outer.resolveCaptured(name)
+	if original.Scope == GlobalScope {
+		return original
+	}
+
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	symbol := &Symbol{Name: name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1, Boxed: true}
+	s.free[name] = symbol
+	return *symbol
+}
+
+// NumLocals is the number of slots the function's frame needs
+func (s *SymbolTable) NumLocals() int {
+	return len(s.names)
+}
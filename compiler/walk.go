@@ -0,0 +1,45 @@
+package compiler
+
+import "jeff/ast"
+
+// capturedNames returns every name used inside the closures created in a function
+func capturedNames(fn *ast.FunctionLiteral) map[string]bool {
+	names := map[string]bool{}
+
+	collect := func(node ast.Node) bool {
+		if identifier, ok := node.(*ast.Indentifier); ok {
+			names[identifier.Value] = true
+		}
+		return true
+	}
+
+	ast.Walk(fn, func(node ast.Node) bool {
+		if inner, ok := node.(*ast.FunctionLiteral); ok && inner != fn {
+			ast.Walk(inner, collect)
+			return false
+		}
+		return true
+	})
+
+	return names
+}
+
+// declaredNames returns the names a function declares with jeff's and import,
+// in the order they appear. Closures inside the function are left out
+func declaredNames(fn *ast.FunctionLiteral) []string {
+	names := []string{}
+
+	ast.Walk(fn, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return node == fn
+		case *ast.JeffStatement:
+			names = append(names, node.Name.Value)
+		case *ast.ImportStatement:
+			names = append(names, node.Name.Value)
+		}
+		return true
+	})
+
+	return names
+}
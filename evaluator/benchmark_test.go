@@ -0,0 +1,52 @@
+package evaluator_test
+
+import (
+	"jeff/lexer"
+	"jeff/object"
+	"jeff/parser"
+	"testing"
+)
+
+// The benchmarks run with each engine like the tests. Run them with
+// go test -run NONE -bench . -benchmem ./evaluator to see the time and allocations per op
+
+func BenchmarkFib(b *testing.B) {
+	benchmark(b, `
+jeff's fib is fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20)`)
+}
+
+func BenchmarkStringBuilding(b *testing.B) {
+	benchmark(b, `
+jeff's out is "";
+jeff's i is 0;
+while (i < 1000) { out is out + "jeff" + "${i}"; i is i + 1 };
+len(out)`)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmark(b, `
+jeff's counter is fn() { jeff's n is 0; fn() { n is n + 1 } };
+jeff's counters is [counter(), counter(), counter()];
+jeff's i is 0;
+while (i < 1000) { counters[i % 3](); i is i + 1 };
+counters[0]()`)
+}
+
+// benchmark parses the program once and then times running it
+func benchmark(b *testing.B, input string) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	b.Run(engine.name, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if result, ok := engine.eval(program, object.NewEnvironment()).(*object.ERROR); ok {
+				b.Fatalf("benchmark failed: %s", result.Inspect())
+			}
+		}
+	})
+}
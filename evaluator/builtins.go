@@ -3,6 +3,9 @@ package evaluator
 import (
 	"fmt"
 	"jeff/object"
+	"math"
+	"strconv"
+	"unicode/utf8"
 )
 
 // Buit in functions for the JPL
@@ -15,12 +18,35 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				// The number of characters, use bytes() for the size in bytes
+				return newInteger(int64(utf8.RuneCountInString(arg.Value)))
+			case *object.Array:
+				return newInteger(int64(len(arg.Elements)))
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
+	// bytes returns the UTF-8 bytes of a string as an array of integers
+	"bytes": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `bytes` must be STRING, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = newInteger(int64(str.Value[i]))
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
 	"jeffsays": {
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
@@ -30,4 +56,204 @@ var builtins = map[string]*object.Builtin{
 			return &object.String{Value: ""}
 		},
 	},
+	// first returns the first element of an array. NULL if the array is empty
+	"first": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return NULL
+		},
+	},
+	// last returns the last element of an array. NULL if the array is empty
+	"last": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return NULL
+		},
+	},
+	// rest returns a new array with every element except the first. NULL if the array is empty
+	"rest": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+
+			return NULL
+		},
+	},
+	// push returns a new array with the element added to the end.
+	// The original array is left untouched
+	"push": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != object.ARRAY_OBJ {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+
+			newElements := make([]object.Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &object.Array{Elements: newElements}
+		},
+	},
+	// raise stops the program with an error that can be caught with try/catch.
+	// An optional second argument sets the error type. e.g. raise("bad input", "ValueError")
+	"raise": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			message, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `raise` must be STRING, got %s", args[0].Type())
+			}
+
+			kind := "Error"
+			if len(args) == 2 {
+				kindArg, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `raise` must be STRING, got %s", args[1].Type())
+				}
+				kind = kindArg.Value
+			}
+
+			return &object.ERROR{Message: message.Value, Kind: kind}
+		},
+	},
+	// int converts a float, string or boolean to an integer. Floats are truncated towards zero
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// Go gives back an arbitrary integer for floats that don't fit
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) ||
+					arg.Value < math.MinInt64 || arg.Value >= -math.MinInt64 {
+					return newError("could not convert %s to INTEGER", arg.Inspect())
+				}
+				return newInteger(int64(arg.Value))
+			case *object.String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return newError("could not convert %q to INTEGER", arg.Value)
+				}
+				return newInteger(value)
+			case *object.Boolean:
+				if arg.Value {
+					return newInteger(1)
+				}
+				return newInteger(0)
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// float converts an integer, string or boolean to a float
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return newError("could not convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Float{Value: 1}
+				}
+				return &object.Float{Value: 0}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	// help returns the doc comment written above a function. e.g.
+	//
+	//	# Adds two numbers
+	//	jeff's add is fn(x, y) { x + y };
+	//	help(add); # returns "Adds two numbers"
+	"help": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch fn := args[0].(type) {
+			case *object.Function:
+				return &object.String{Value: fn.Doc}
+			case *object.Closure:
+				return &object.String{Value: fn.Doc}
+			default:
+				return newError("argument to `help` must be FUNCTION, got %s", args[0].Type())
+			}
+		},
+	},
+	// is_null reports whether the value is null
+	"is_null": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return nativeBoolToBooleanObject(args[0] == NULL)
+		},
+	},
 }
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
 	"jeff/ast"
 	"jeff/object"
+	"jeff/resolver"
+	"math"
+	"strings"
+	"unicode/utf8"
 )
 
 // Dont need separate instances of booleans and null. True will always be true
@@ -11,15 +16,59 @@ var (
 	NULL  = &object.Null{}
 	RIGHT = &object.Boolean{Value: true}
 	HUANG = &object.Boolean{Value: false}
+
+	// Break and continue carry no data so they can be shared too
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// Integers can't be changed once made, so the small ones that come up all the time are shared
+const (
+	minSmallInteger = -128
+	maxSmallInteger = 1024
 )
 
+var smallIntegers [maxSmallInteger - minSmallInteger + 1]*object.Integer
+
+func init() {
+	for i := range smallIntegers {
+		smallIntegers[i] = &object.Integer{Value: int64(i + minSmallInteger)}
+	}
+}
+
+// newInteger returns an integer object, reusing the shared one for small values
+func newInteger(value int64) *object.Integer {
+	if value >= minSmallInteger && value <= maxSmallInteger {
+		return smallIntegers[value-minSmallInteger]
+	}
+	return &object.Integer{Value: value}
+}
+
+// CheckedArithmetic makes integer +, -, *, ** and negation return an error when
+// the result doesn't fit in an int64, instead of silently wrapping around. Off by default
+var CheckedArithmetic = false
+
 // Eval recursivly traverses an AST and returns the internal
-// object representation of the AST node
+// object representation of the AST node.
+// Errors are given the position of the innermost node they came from
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	if err, ok := result.(*object.ERROR); ok && !err.Position.IsValid() {
+		err.Position = node.Position()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
+		if err := resolve(node, env); err != nil {
+			return err
+		}
 		return evalProgram(node.Statements, env)
 
 	case *ast.ExpressionStatement:
@@ -36,11 +85,20 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 
-		env.Set(node.Name.Value, val)
+		nameObject(val, node.Name.Value, node.Doc)
+		env.Define(node.Name.Slot, node.Name.Value, val)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Env: env, Body: body, Locals: node.Locals}
 
 	// Expressions
 	case *ast.CallExpression:
@@ -53,11 +111,44 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		// Calls in tail position are handed back to applyFunction to make, so deep tail
+		// recursion runs in constant stack
+		if fn, ok := function.(*object.Function); ok && node.Tail {
+			return &object.TailCall{Function: fn, Arguments: args, Call: node}
+		}
+
+		result := applyFunction(function, args)
+
+		// Errors coming out of a JPL function record the call so a traceback can be printed
+		if err, ok := result.(*object.ERROR); ok {
+			if fn, ok := function.(*object.Function); ok {
+				err.Stack = append(err.Stack, object.Frame{Function: fn.Name, Position: node.Function.Position()})
+			}
+		}
+
+		return result
 	case *ast.Indentifier:
 		return evalIdentifier(node, env)
 
+	case *ast.AssignExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if _, ok := env.AssignAt(node.Name.Depth, node.Name.Slot, node.Name.Value, val); !ok {
+			return newError("identifier not found: " + node.Name.Value)
+		}
+		return val
+
 	case *ast.InfixExpression:
+		if node.Operator == "and" || node.Operator == "or" {
+			return evalLogicalExpression(node, env)
+		}
+		if node.Operator == "??" {
+			return evalCoalesceExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -75,21 +166,95 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 
+	// Literals always give the same value, so their object is only made once
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Object == nil {
+			node.Object = newInteger(node.Value)
+		}
+		return node.Object.(*object.Integer)
+	case *ast.FloatLiteral:
+		if node.Object == nil {
+			node.Object = &object.Float{Value: node.Value}
+		}
+		return node.Object.(*object.Float)
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		if node.Object == nil {
+			node.Object = &object.String{Value: node.Value}
+		}
+		return node.Object.(*object.String)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return evalMemberExpression(left, node.Property.Value)
+	case *ast.StructLiteral:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		return &object.StructType{Fields: fields}
+	case *ast.FieldAssignExpression:
+		return evalFieldAssignExpression(node, env)
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.Null:
+		return NULL
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	}
 	return nil
 }
 
+// nameObject gives functions the first name they are bound to for stack traces,
+// and the doc comment above it for help(). Structs are named the same way,
+// the name is shown when instances are printed
+func nameObject(val object.Object, name string, doc string) {
+	switch val := val.(type) {
+	case *object.Function:
+		if val.Name == "" {
+			val.Name = name
+			val.Doc = doc
+		}
+	case *object.Closure:
+		if val.Name == "" {
+			val.Name = name
+			val.Doc = doc
+		}
+	case *object.StructType:
+		if val.Name == "" {
+			val.Name = name
+		}
+	}
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -109,28 +274,128 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// Calls made in tail position, most recent last. An error passing out of them
+		// still gets them in its stack
+		var tailCalls []*object.TailCall
+
+		for {
+			result := callFunction(fn, args)
+
+			tailCall, ok := result.(*object.TailCall)
+			if !ok {
+				if err, ok := result.(*object.ERROR); ok && len(tailCalls) > 0 {
+					if !err.Position.IsValid() {
+						err.Position = tailCalls[len(tailCalls)-1].Call.Position()
+					}
+					for i := len(tailCalls) - 1; i >= 0; i-- {
+						err.Stack = append(err.Stack, object.Frame{Function: tailCalls[i].Function.Name, Position: tailCalls[i].Call.Function.Position()})
+					}
+				}
+				return result
+			}
+
+			fn, args = tailCall.Function, tailCall.Arguments
+			tailCalls = append(tailCalls, tailCall)
+			if len(tailCalls) > object.MaxTailCalls {
+				tailCalls = tailCalls[1:]
+			}
+		}
 
 	case *object.Builtin:
 		return fn.Fn(args...)
+	case *object.StructType:
+		return newStructInstance(fn, args)
 	default:
 		return newError("not a function: %s", fn)
 	}
 }
 
+// callFunction runs the body of a JPL function. A call in tail position is
+// returned as a TailCall for the caller to make
+func callFunction(fn *object.Function, args []object.Object) object.Object {
+	if err := checkArity(fn, len(args)); err != nil {
+		return err
+	}
+
+	extendedEnv, err := extendFunctionEnv(fn, args)
+	if err != nil {
+		return err
+	}
+
+	evaluated := Eval(fn.Body, extendedEnv)
+	if isLoopControl(evaluated) {
+		return newError("%s outside of loop", evaluated.Inspect())
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// checkArity returns an error if the function can't be called with that many arguments
+func checkArity(fn *object.Function, count int) *object.ERROR {
+	required := 0
+	for i := range fn.Parameters {
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			required += 1
+		}
+	}
+
+	return arityError(fn.Name, count, required, len(fn.Parameters), fn.Rest != nil)
+}
+
+// arityError returns an error if a function with that many required and optional parameters
+// can't be called with count arguments. Variadic functions take any number over the required ones
+func arityError(name string, count int, required int, params int, variadic bool) *object.ERROR {
+	switch {
+	case variadic && count < required:
+		return newError("wrong number of arguments to %s. got=%d, want at least %d", frameName(name), count, required)
+	case !variadic && (count < required || count > params):
+		if required == params {
+			return newError("wrong number of arguments to %s. got=%d, want=%d", frameName(name), count, required)
+		}
+		return newError("wrong number of arguments to %s. got=%d, want=%d to %d", frameName(name), count, required, params)
+	}
+
+	return nil
+}
+
+// frameName gives anonymous functions a name to show in errors
+func frameName(name string) string {
+	if name == "" {
+		return "<fn>"
+	}
+	return name
+}
+
+// extendFunctionEnv binds the arguments to the parameters in a new environment.
+// Missing arguments get their default, evaluated in the new environment so they can use earlier parameters.
+// Expects the arity to have already been checked
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+) (*object.Environment, object.Object) {
+	env := object.NewFunctionEnvironment(fn.Env, len(fn.Locals))
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Define(param.Slot, param.Value, args[paramIdx])
+			continue
+		}
+
+		val := Eval(fn.Defaults[paramIdx], env)
+		if isError(val) {
+			return nil, val
+		}
+		env.Define(param.Slot, param.Value, val)
 	}
 
-	return env
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Define(fn.Rest.Slot, fn.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -142,11 +407,12 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalIdentifier(node *ast.Indentifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if val, ok := env.GetAt(node.Depth, node.Slot, node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	// A local that hasn't been set yet doesn't fall back to a builtin with the same name
+	if builtin, ok := builtins[node.Value]; ok && node.Slot < 0 {
 		return builtin
 	}
 
@@ -154,6 +420,189 @@ func evalIdentifier(node *ast.Indentifier, env *object.Environment) object.Objec
 
 }
 
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalStringIndexExpression returns the character at the index as a string.
+// Indexes outside the string return NULL
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	// Strings are indexed by character rather than byte
+	characters := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(characters)) {
+		return NULL
+	}
+
+	return &object.String{Value: string(characters[idx])}
+}
+
+// evalSliceExpression evaluates the value being sliced and both bounds, then slices it
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var start, end object.Object
+	if node.Start != nil {
+		start = Eval(node.Start, env)
+		if isError(start) {
+			return start
+		}
+	}
+	if node.End != nil {
+		end = Eval(node.End, env)
+		if isError(end) {
+			return end
+		}
+	}
+
+	return sliceObject(left, start, end)
+}
+
+// sliceObject returns a new string or array containing the elements from start up to
+// (but not including) end. A nil start or end means the start or end of the whole thing.
+// Bounds past either end are clamped, so slices never error on their range
+func sliceObject(left, startBound, endBound object.Object) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	case *object.Array:
+		length = int64(len(left.Elements))
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := sliceBound(startBound, 0, length)
+	if err != nil {
+		return err
+	}
+
+	end, err := sliceBound(endBound, length, length)
+	if err != nil {
+		return err
+	}
+
+	if end < start {
+		end = start
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		return &object.String{Value: string([]rune(left.Value)[start:end])}
+	default:
+		elements := make([]object.Object, end-start)
+		copy(elements, left.(*object.Array).Elements[start:end])
+		return &object.Array{Elements: elements}
+	}
+}
+
+// sliceBound checks one side of a slice is an integer and clamps it between 0 and length
+func sliceBound(bound object.Object, fallback, length int64) (int64, object.Object) {
+	if bound == nil {
+		return fallback, nil
+	}
+
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError("slice index must be INTEGER, got %s", bound.Type())
+	}
+
+	if integer.Value < 0 {
+		return 0, nil
+	}
+	if integer.Value > length {
+		return length, nil
+	}
+	return integer.Value, nil
+}
+
+// evalInterpolatedString evaluates each part of the string and joins them together
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// evalArrayIndexExpression returns the element at the index.
+// Indexes outside the array return NULL rather than an error
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	max := int64(len(elements) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return elements[idx]
+}
+
+// evalHashIndexExpression returns the value stored against the key.
+// Keys that are not in the hash return NULL
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// evalHashLiteral evaluates the pairs in the order they were written,
+// so when a key is repeated the last value wins
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -169,6 +618,99 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// evalWhileStatement runs the body until the condition is no longer truthy.
+// break and continue from the body are handled here and go no further,
+// while returns and errors stop the loop and are passed up
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			break
+		}
+
+		result := Eval(ws.Body, env)
+		if result != nil {
+			resultType := result.Type()
+			if resultType == object.RETURN_OBJ || resultType == object.ERROR_OBJ {
+				return result
+			}
+			if resultType == object.BREAK_OBJ {
+				break
+			}
+		}
+	}
+
+	return NULL
+}
+
+// evalTryExpression runs the try block, and if it errors binds the error
+// to the catch parameter and runs the handler instead.
+// Returns and loop control from either block are passed up untouched
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	err, ok := result.(*object.ERROR)
+	if !ok {
+		return result
+	}
+
+	// In a function the parameter has a slot of its own
+	if te.Param.Slot >= 0 {
+		env.Define(te.Param.Slot, te.Param.Value, errorToHash(err))
+		return Eval(te.Handler, env)
+	}
+
+	// At the top level it's kept by name, so the global it hides is put back when the handler
+	// finishes. Handlers that raise, break or continue leave it as the vm does
+	hidden, ok := env.Get(te.Param.Value)
+	env.Set(te.Param.Value, errorToHash(err))
+
+	result = Eval(te.Handler, env)
+	switch result.(type) {
+	case *object.ERROR, *object.Break, *object.Continue, *object.Return:
+		return result
+	}
+
+	if ok {
+		env.Set(te.Param.Value, hidden)
+	} else {
+		env.Delete(te.Param.Value)
+	}
+	return result
+}
+
+// errorToHash converts an error into a hash so scripts can inspect it without it propagating.
+// e.g. {"message": "...", "type": "RuntimeError", "file": "main.jeff", "line": 1, "column": 4}
+func errorToHash(err *object.ERROR) *object.Hash {
+	kind := err.Kind
+	if kind == "" {
+		kind = "RuntimeError"
+	}
+
+	fields := []struct {
+		key   string
+		value object.Object
+	}{
+		{"message", &object.String{Value: err.Message}},
+		{"type", &object.String{Value: kind}},
+		{"file", &object.String{Value: err.Position.File}},
+		{"line", newInteger(int64(err.Position.Line))},
+		{"column", newInteger(int64(err.Position.Column))},
+	}
+
+	hash := object.NewHash()
+	for _, field := range fields {
+		key := &object.String{Value: field.key}
+		hash.Set(key.HashKey(), object.HashPair{Key: key, Value: field.value})
+	}
+
+	return hash
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -197,10 +739,21 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// At least one side is a float so the integer side gets promoted
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
 		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalStringRepetition(left, right)
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right, left)
+	case operator == "==":
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -208,26 +761,92 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("uknown operator: %s %s %s", left, operator, right)
+// evalCoalesceExpression evaluates a ?? b. The right side is only evaluated if the left side is null.
+// Errors on the left side are still returned
+func evalCoalesceExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) || left != NULL {
+		return left
+	}
+
+	return Eval(node.Right, env)
+}
+
+// evalLogicalExpression evaluates and/or. The right side is only evaluated
+// if the left side doesn't already decide the result
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Operator == "and" && !isTruthy(left) {
+		return HUANG
 	}
 
+	if node.Operator == "or" && isTruthy(left) {
+		return RIGHT
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalStringInfixExpression handles concatenation and comparison of strings.
+// Comparisons are lexicographic
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 
-	return &object.String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		// Adding an empty string doesn't need a copy
+		if leftVal == "" {
+			return right
+		}
+		if rightVal == "" {
+			return left
+		}
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
-func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
+// evalStringRepetition repeats the string count times. i.e. "ab" * 3 is "ababab"
+func evalStringRepetition(str, count object.Object) object.Object {
+	value := str.(*object.String).Value
+	times := count.(*object.Integer).Value
 
-	// Note for boolean object comparison, we can use the actual objects because all TRUE and FALSE objects
-	// are the same in Jeff (same memory location as well)
+	if times < 0 {
+		return newError("negative string repeat count: %d", times)
+	}
+
+	return &object.String{Value: strings.Repeat(value, int(times))}
+}
+
+func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
 	switch operator {
 	case "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -239,19 +858,154 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	// Dividing by zero would panic in Go so catch it first
+	if rightVal == 0 && (operator == "/" || operator == "%" || operator == "//") {
+		return newError("division by zero: %d %s %d", leftVal, operator, rightVal)
+	}
+
+	switch operator {
+	case "+":
+		value, overflow := addInt64(leftVal, rightVal)
+		return checkedInteger(value, overflow, leftVal, operator, rightVal)
+	case "-":
+		value, overflow := subtractInt64(leftVal, rightVal)
+		return checkedInteger(value, overflow, leftVal, operator, rightVal)
+	case "/":
+		return newInteger(leftVal / rightVal)
+	case "*":
+		value, overflow := multiplyInt64(leftVal, rightVal)
+		return checkedInteger(value, overflow, leftVal, operator, rightVal)
+	case "%":
+		return newInteger(leftVal % rightVal)
+	case "//":
+		return newInteger(floorDivide(leftVal, rightVal))
+	case "**":
+		// Negative powers can't be represented as integers
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+		value, overflow := integerPower(leftVal, rightVal)
+		return checkedInteger(value, overflow, leftVal, operator, rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// floorDivide divides and rounds down rather than towards zero. i.e. -7 // 2 is -4
+func floorDivide(left, right int64) int64 {
+	quotient := left / right
+	if (left%right != 0) && ((left < 0) != (right < 0)) {
+		quotient -= 1
+	}
+	return quotient
+}
+
+// integerPower raises base to a non negative exponent by repeated squaring.
+// Also reports if the result overflowed
+func integerPower(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	overflowed := false
+
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			var overflow bool
+			result, overflow = multiplyInt64(result, base)
+			overflowed = overflowed || overflow
+		}
+
+		exponent >>= 1
+
+		// Only square the base if it will be used again
+		if exponent > 0 {
+			var overflow bool
+			base, overflow = multiplyInt64(base, base)
+			overflowed = overflowed || overflow
+		}
+	}
+
+	return result, overflowed
+}
+
+// checkedInteger wraps the result of integer arithmetic in an object.
+// If checked arithmetic is on and the result overflowed then returns an error instead
+func checkedInteger(value int64, overflow bool, left int64, operator string, right int64) object.Object {
+	if overflow && CheckedArithmetic {
+		return newError("integer overflow: %d %s %d", left, operator, right)
+	}
+	return newInteger(value)
+}
+
+// addInt64 returns the wrapped sum and whether it overflowed
+func addInt64(left, right int64) (int64, bool) {
+	result := left + right
+	return result, (right > 0 && result < left) || (right < 0 && result > left)
+}
+
+// subtractInt64 returns the wrapped difference and whether it overflowed
+func subtractInt64(left, right int64) (int64, bool) {
+	result := left - right
+	return result, (right > 0 && result > left) || (right < 0 && result < left)
+}
+
+// multiplyInt64 returns the wrapped product and whether it overflowed
+func multiplyInt64(left, right int64) (int64, bool) {
+	if left == 0 || right == 0 {
+		return 0, false
+	}
+
+	result := left * right
+
+	// MinInt64 * -1 wraps back to MinInt64, which the division check can't see
+	if (left == -1 && right == math.MinInt64) || (right == -1 && left == math.MinInt64) {
+		return result, true
+	}
+
+	return result, result/right != left
+}
+
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	if rightVal == 0 && (operator == "/" || operator == "%" || operator == "//") {
+		return newError("division by zero: %s %s %s", left.Inspect(), operator, right.Inspect())
+	}
+
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "//":
+		return &object.Float{Value: math.Floor(leftVal / rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -261,15 +1015,118 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-// evalMinusPrefixOperatorExpression converts integer value of object
-// into its negative counterpart. If object is not an integer; return NULL
+// objectsEqual is what == means for every type. Numbers, strings, booleans and null are equal by value.
+// Arrays, hashes and structs are equal when everything in them is equal.
+// Anything else (functions, builtins, modules...) is only equal to itself.
+// Values of different types are never equal, except integers and floats with the same value
+func objectsEqual(left, right object.Object) bool {
+	return equal(left, right, nil)
+}
+
+// equal compares two objects for objectsEqual. Structs can point at each other, so seen holds
+// the pairs of structs already being compared further up. Meeting one again means everything
+// on the way round was equal, so it isn't compared again
+func equal(left, right object.Object, seen map[[2]*object.StructInstance]bool) bool {
+	// Floats are left out so NaN isn't equal to itself
+	if left == right && left.Type() != object.FLOAT_OBJ {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.Integer:
+		if right, ok := right.(*object.Integer); ok {
+			return left.Value == right.Value
+		}
+		return isNumber(right) && toFloat(left) == toFloat(right)
+	case *object.Float:
+		return isNumber(right) && left.Value == toFloat(right)
+	case *object.String:
+		right, ok := right.(*object.String)
+		return ok && left.Value == right.Value
+	case *object.Boolean:
+		right, ok := right.(*object.Boolean)
+		return ok && left.Value == right.Value
+	case *object.Null:
+		return right.Type() == object.NULL_OBJ
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i := range left.Elements {
+			if !equal(left.Elements[i], right.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		for key, pair := range left.Pairs {
+			other, ok := right.Pairs[key]
+			if !ok || !equal(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+	case *object.StructInstance:
+		right, ok := right.(*object.StructInstance)
+		if !ok || left.StructType != right.StructType {
+			return false
+		}
+		pair := [2]*object.StructInstance{left, right}
+		if seen[pair] {
+			return true
+		}
+		if seen == nil {
+			seen = map[[2]*object.StructInstance]bool{}
+		}
+		seen[pair] = true
+		for name, value := range left.Fields {
+			if !equal(value, right.Fields[name], seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// isNumber returns true for integers and floats
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat returns the value of an integer or float object as a float64
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+// evalMinusPrefixOperatorExpression converts the value of a number object
+// into its negative counterpart. If object is not a number; return an error
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		// The smallest int64 has no positive counterpart
+		if right.Value == math.MinInt64 && CheckedArithmetic {
+			return newError("integer overflow: -(%d)", right.Value)
+		}
+		return newInteger(-right.Value)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 // evalBangOperatorExpression converts true to false and false to true.
@@ -287,6 +1144,16 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
+// resolve works out where the program's variables live before it runs. Globals it doesn't
+// declare have to already be in env or be builtins
+func resolve(program *ast.Program, env *object.Environment) *object.ERROR {
+	return resolver.Resolve(program, func(name string) bool {
+		_, ok := env.Get(name)
+		_, builtin := builtins[name]
+		return ok || builtin
+	})
+}
+
 func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
@@ -298,6 +1165,11 @@ func evalProgram(statements []ast.Statement, env *object.Environment) object.Obj
 			return result.Value
 		case *object.ERROR:
 			return result
+		case *object.Break, *object.Continue:
+			// Break and continue are shared so they don't know where they are, but the statement does
+			err := newError("%s outside of loop", result.Inspect())
+			err.Position = statement.Position()
+			return err
 		}
 	}
 
@@ -316,6 +1188,10 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 			if resultType == object.RETURN_OBJ || resultType == object.ERROR_OBJ {
 				return result
 			}
+			// break and continue also stop the block so the loop can deal with them
+			if resultType == object.BREAK_OBJ || resultType == object.CONTINUE_OBJ {
+				return result
+			}
 		}
 	}
 
@@ -335,6 +1211,13 @@ func newError(format string, a ...interface{}) *object.ERROR {
 	return &object.ERROR{Message: fmt.Sprintf(format, a...)}
 }
 
+func isLoopControl(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.BREAK_OBJ || obj.Type() == object.CONTINUE_OBJ
+	}
+	return false
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
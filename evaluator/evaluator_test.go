@@ -1,12 +1,46 @@
-package evaluator
+package evaluator_test
 
 import (
+	"fmt"
+	"jeff/ast"
+	. "jeff/evaluator"
 	"jeff/lexer"
 	"jeff/object"
 	"jeff/parser"
+	"jeff/vm"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
+// Every test runs once with each engine, so the evaluator and the vm always agree
+var engines = []struct {
+	name     string
+	eval     func(program *ast.Program, env *object.Environment) object.Object
+	evalFile func(path string, program *ast.Program, env *object.Environment) object.Object
+}{
+	{"evaluator", func(program *ast.Program, env *object.Environment) object.Object { return Eval(program, env) }, EvalFile},
+	{"vm", vm.Eval, vm.EvalFile},
+}
+
+// The engine the tests are currently running with
+var engine = engines[0]
+
+func TestMain(m *testing.M) {
+	status := 0
+
+	for _, e := range engines {
+		engine = e
+		if result := m.Run(); result != 0 {
+			fmt.Printf("FAIL with the %s engine\n", e.name)
+			status = result
+		}
+	}
+
+	os.Exit(status)
+}
+
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -50,6 +84,15 @@ func TestEvalIntegerExpression(t *testing.T) {
 			"10 / 5 + 2 * 5",
 			12,
 		},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"7 // 2", 3},
+		{"-7 // 2", -4},
+		{"7 // -2", -4},
 	}
 
 	for _, testCase := range tests {
@@ -58,10 +101,168 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"7.5 % 2", 1.5},
+		{"2.0 ** 3", 8},
+		{"2 ** -1", 0.5},
+		{"7.0 // 2", 3},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2 * 1.5 - 1", 2},
+		{"float(3)", 3},
+		{`float("1.25")`, 1.25},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+		testFloatObject(t, evaluated, testCase.expected)
+	}
+}
+
+func TestNumberConversions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(2.9)", 2},
+		{"int(-2.9)", -2},
+		{"int(5)", 5},
+		{`int("42")`, 42},
+		{"int(right)", 1},
+		{`int("jeff")`, `could not convert "jeff" to INTEGER`},
+		{`int(float("NaN"))`, "could not convert NaN to INTEGER"},
+		{`int(float("-Inf"))`, "could not convert -Inf to INTEGER"},
+		{"int(1e19)", "could not convert 1e+19 to INTEGER"},
+		{"int(-9223372036854775808.0)", -9223372036854775807 - 1},
+		{`float("jeff")`, `could not convert "jeff" to FLOAT`},
+		{"float([])", "argument to `float` not supported, got ARRAY"},
+		{"1.5 < 2", true},
+		{"2 == 2.0", true},
+		{"2.5 != 2.5", false},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.5", "2.5"},
+		{"3.0", "3.0"},
+		{"float(7)", "7.0"},
+		{"1e21", "1e+21"},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+		if evaluated.Inspect() != testCase.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", testCase.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"-(-9223372036854775807 - 1)", "integer overflow: -(-9223372036854775808)"},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"2 ** 62", 4611686018427387904},
+		{"(-2) ** 63", -9223372036854775808},
+	}
+
+	CheckedArithmetic = true
+	defer func() { CheckedArithmetic = false }()
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestUncheckedArithmeticWraps(t *testing.T) {
+	testIntegerObject(t, testEval("9223372036854775807 + 1"), -9223372036854775808)
+}
+
+func TestSharedObjects(t *testing.T) {
+	if Integer(7) != Integer(7) || Integer(-128) != Integer(-128) || Integer(1024) != Integer(1024) {
+		t.Errorf("small integers are not shared")
+	}
+	if Integer(1025) == Integer(1025) {
+		t.Errorf("large integers are shared")
+	}
+
+	tests := []string{
+		`jeff's f is fn() { 123456 }; [f(), f()]`,
+		`jeff's f is fn() { 1.5 }; [f(), f()]`,
+		`jeff's f is fn() { "jeff" }; [f(), f()]`,
+		`[2 + 3, 10 // 2]`,
+	}
+
+	for _, input := range tests {
+		array, ok := testEval(input).(*object.Array)
+		if !ok || len(array.Elements) != 2 {
+			t.Fatalf("wrong result for %q", input)
+		}
+
+		if array.Elements[0] != array.Elements[1] {
+			t.Errorf("%q made a new object each time. got=%p and %p", input, array.Elements[0], array.Elements[1])
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected bool
+		expected interface{}
 	}{
 		{
 			"right",
@@ -139,11 +340,46 @@ func TestEvalBooleanExpression(t *testing.T) {
 			"(1 > 4) == huang",
 			true,
 		},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"2 <= 1.5", false},
+		{"right and right", true},
+		{"right and huang", false},
+		{"huang and right", false},
+		{"huang or right", true},
+		{"huang or huang", false},
+		{"1 < 2 and 2 < 3", true},
+		{"1 > 2 or 2 > 3", false},
+		{"1 and 0", true},
+		// Undefined names are found before the program runs, even where they'd be skipped
+		{"huang and foobar", "identifier not found: foobar"},
+		{"right or foobar", "identifier not found: foobar"},
+		{"huang and 1 + huang", false},
+		{"right or 1 + huang", true},
+		{"jeff's x is 0; huang and (x is 1); x == 0", true},
+		{"jeff's x is 0; right and (x is 1); x == 1", true},
 	}
 
 	for _, testCase := range tests {
 		evaluated := testEval(testCase.input)
-		testBooleanObject(t, evaluated, testCase.expected)
+
+		switch expected := testCase.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
 	}
 }
 
@@ -231,6 +467,26 @@ func TestErrorHandling(t *testing.T) {
 		{"right + huang", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"5; right + huang; 5;", "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar", "identifier not found: foobar"},
+		{`{"name": "jeff"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: "jeff"};`, "unusable as hash key: FUNCTION"},
+		{"break;", "break outside of loop"},
+		{"fn() { continue; }()", "continue outside of loop"},
+		{"while (foobar) { 1 }", "identifier not found: foobar"},
+		{"foobar is 1", "identifier not found: foobar"},
+		{`raise("jeff broke it")`, "jeff broke it"},
+		{`raise(1)`, "argument to `raise` must be STRING, got INTEGER"},
+		{`try { foobar } catch (err) { raise(err["message"] + " again") }`, "identifier not found: foobar"},
+		{`try { 1 + huang } catch (err) { raise(err["message"] + " again") }`, "type mismatch: INTEGER + BOOLEAN again"},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"1 % 0", "division by zero: 1 % 0"},
+		{"1 // 0", "division by zero: 1 // 0"},
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"right and foobar", "identifier not found: foobar"},
+		{"if (huang) { foobar }", "identifier not found: foobar"},
+		{"jeff's f is fn() { foobar }", "identifier not found: foobar"},
+		{"jeff's f is fn() { jeff's x is later; jeff's later is 1; x }; f()", "identifier not found: later"},
+		{"jeff's f is fn() { if (huang) { jeff's x is 1 }; x }; f()", "identifier not found: x"},
+		{`jeff's f is fn() { if (huang) { jeff's len is 1 }; len("ab") }; f()`, "identifier not found: len"},
 	}
 
 	for _, testCase := range tests {
@@ -249,104 +505,267 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
-func TestJeffStatements(t *testing.T) {
-
+func TestErrorPositions(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected int64
+		input            string
+		expectedPosition string
 	}{
-		{"jeff's x is 5; x", 5},
-		{"jeff's a is 5 * 5;a;", 25},
-		{"jeff's a is 5; jeff's b is 5; jeff's c is a * b; c;", 25},
+		{"5 + right", "1:3"},
+		{"jeff's x is 1;\n  foobar", "2:3"},
+		{"jeff's f is fn() {\n  1 + huang;\n};\nf()", "2:5"},
+		{"len(1)", "1:4"},
+		{"jeffsays(1);\nfn() {\n  1 + foobar\n}", "3:7"},
+		{"1;\n\n  continue;", "3:3"},
 	}
 
 	for _, testCase := range tests {
-		testIntegerObject(t, testEval(testCase.input), testCase.expected)
-	}
+		evaluated := testEval(testCase.input)
+
+		errObj, ok := evaluated.(*object.ERROR)
+		if !ok {
+			t.Errorf("no error object returned. Got %T (%+v)", evaluated, evaluated)
+			continue
+		}
 
+		if errObj.Position.String() != testCase.expectedPosition {
+			t.Errorf("Unexpected position. Expected %s but got %s", testCase.expectedPosition, errObj.Position)
+		}
+	}
 }
 
-func TestFunctionObject(t *testing.T) {
-	input := "fn(x) {  x + 2 ; };"
+func TestErrorStackTrace(t *testing.T) {
+	input := `jeff's inner is fn(x) {
+  x + huang
+};
+jeff's outer is fn() {
+  fn() { inner(1) }()
+};
+outer();`
 
 	evaluated := testEval(input)
-	fn, ok := evaluated.(*object.Function)
 
+	errObj, ok := evaluated.(*object.ERROR)
 	if !ok {
-		t.Fatalf("Evaluated %T(%+v) could not be converted to function", evaluated, evaluated)
+		t.Fatalf("no error object returned. Got %T (%+v)", evaluated, evaluated)
 	}
 
-	if len(fn.Parameters) != 1 {
-		t.Fatalf("Expected lengh of parameters to be 1, but got %d", len(fn.Parameters))
+	expected := `Traceback (most recent call last):
+  File "<repl>", line 7, column 1, in <main>
+  File "<repl>", line 5, column 3, in outer
+  File "<repl>", line 5, column 10, in <fn>
+  File "<repl>", line 2, column 5, in inner
+ERROR: type mismatch: INTEGER + BOOLEAN`
+
+	if errObj.Traceback() != expected {
+		t.Errorf("Unexpected traceback. Expected\n%s\nbut got\n%s", expected, errObj.Traceback())
 	}
+}
 
-	if fn.Parameters[0].String() != "x" {
-		t.Fatalf("Expected function parameter to be x but got %s", fn.Parameters[0])
+func TestHelp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"# Adds two numbers\njeff's add is fn(x, y) { x + y }; help(add)", "Adds two numbers"},
+		{"# one\n# two\njeff's f is fn() { 1 }; help(f)", "one\ntwo"},
+		{"jeff's f is fn() { 1 }; help(f)", ""},
+		{"# Only the first name counts\njeff's f is fn() { 1 };\n# Not this one\njeff's g is f; help(g)", "Only the first name counts"},
 	}
 
-	expectedBody := "(x + 2)"
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
 
-	if fn.Body.String() != expectedBody {
-		t.Fatalf("Expected function body to be %s but got %s", expectedBody, fn.Body)
+		if str.Value != tt.expected {
+			t.Errorf("help wrong. expected=%q, got=%q", tt.expected, str.Value)
+		}
 	}
 }
 
-func TestFunctionApplication(t *testing.T) {
+func TestMethods(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected int64
+		expected interface{}
 	}{
-		{"jeff's identity is fn(x) { x; }; identity(5);", 5},
-		{"jeff's identity is fn(x) { return x; }; identity(5);", 5},
-		{"jeff's double is fn(x) { x * 2; }; double(5);", 10},
-		{"jeff's add is fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"jeff's add is fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
+		{`"abc".upper()`, "ABC"},
+		{`"HéLLO".lower()`, "héllo"},
+		{`"  hi  ".trim()`, "hi"},
+		{`"a,b,c".split(",")`, []string{"a", "b", "c"}},
+		{`"jeff".contains("ef")`, true},
+		{`"jeff".contains("x")`, false},
+		{`"a-b-c".replace("-", "+")`, "a+b+c"},
+		{`5.abs()`, 5},
+		{`-5.abs()`, -5},
+		{`(-5).abs()`, 5},
+		{`jeff's x is -2.5; x.abs()`, 2.5},
+		{`2.5.floor()`, 2.0},
+		{`2.5.ceil()`, 3.0},
+		{`2.5.round()`, 3.0},
+		{`[1, "a", right].join(", ")`, "1, a, right"},
+		{`jeff's up is "abc".upper; up()`, "ABC"},
+		{`"abc".upper(1)`, "wrong number of arguments. got=1, want=0"},
+		{`"a,b".split(1)`, "argument to `split` must be STRING, got INTEGER"},
+		{`"abc".missing()`, "STRING has no method missing"},
+		{`right.upper()`, "BOOLEAN has no method upper"},
 	}
 
-	for _, testCase := range tests {
-		testIntegerObject(t, testEval(testCase.input), testCase.expected)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch result := evaluated.(type) {
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("wrong string for %q. expected=%q, got=%q", tt.input, expected, result.Value)
+				}
+			case *object.ERROR:
+				if result.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		case []string:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("wrong array for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			for i, element := range expected {
+				if str, ok := array.Elements[i].(*object.String); !ok || str.Value != element {
+					t.Errorf("wrong element %d. expected=%q, got=%+v", i, element, array.Elements[i])
+				}
+			}
+		}
 	}
 }
 
-func TestStringLiteral(t *testing.T) {
-	input := `"Hello World!"`
-
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+func TestNull(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"jeff's x is null; x", nil},
+		{"null == null", true},
+		{"null == if (huang) { 1 }", true},
+		{"null != 0", true},
+		{"!null", true},
+		{"is_null(null)", true},
+		{"is_null(if (huang) { 1 })", true},
+		{"is_null(0)", false},
+		{`is_null("")`, false},
+		{"null ?? 5", 5},
+		{"3 ?? 5", 3},
+		{"huang ?? 5", false},
+		{"null ?? null ?? 7", 7},
+		{"[1][5] ?? 2", 2},
+		{`{"a": 1}["b"] ?? 0`, 0},
+		{"jeff's x is 0; 1 ?? (x is 1); x", 0},
+		{"jeff's x is 0; null ?? (x is 1); x", 1},
+		{"is_null()", "wrong number of arguments. got=0, want=1"},
+		{"(1 + right) ?? 2", "type mismatch: INTEGER + BOOLEAN"},
+		{"null + 1", "type mismatch: NULL + INTEGER"},
 	}
 
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
 	}
 }
 
-func TestStringConcatenation(t *testing.T) {
-	input := `"Hello" + " " + "World!"`
-
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`1 == "1"`, false},
+		{`1 != "1"`, true},
+		{`right == 1`, false},
+		{`"a" != [1]`, true},
+		{`1 == 1.0`, true},
+		{`jeff's x is float("NaN"); x == x`, false},
+		{`if (huang) { 1 } == if (huang) { 2 }`, true},
+		{`if (huang) { 1 } == 0`, false},
+		{`[1, "a", [right]] == [1, "a", [right]]`, true},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, 2] != [2, 1]`, true},
+		{`{"a": 1, 2: [3]} == {2: [3], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`jeff's f is fn(x) { x }; f == f`, true},
+		{`fn(x) { x } == fn(x) { x }`, false},
+		{`len == len`, true},
+		{`len == first`, false},
+		{`"abc".upper == "abc".upper`, false},
+		{`jeff's Point is struct { x, y }; Point == Point`, true},
+		{`[1, 2].contains(2)`, true},
+		{`[1, [2]].contains([2])`, true},
+		{`[1, 2].contains("2")`, false},
 	}
 
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testBooleanObject(t, evaluated, tt.expected) {
+			t.Errorf("input was %q", tt.input)
+		}
 	}
 }
 
-func TestBuiltinFunctions(t *testing.T) {
+func TestStructs(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{"jeff's Point is struct { x, y }; Point(1, 2).x", 1},
+		{"jeff's Point is struct { x, y }; jeff's p is Point(1, 2); p.y", 2},
+		{"jeff's Point is struct { x, y }; jeff's p is Point(1, 2); p.x is 5; p.x", 5},
+		{"jeff's Point is struct { x, y }; jeff's p is Point(1, 2); jeff's q is p; q.x is 5; p.x", 5},
+		{"jeff's Point is struct { x, y }; Point(1, 2) == Point(1, 2)", true},
+		{"jeff's Point is struct { x, y }; Point(1, 2) == Point(1, 3)", false},
+		{"jeff's Point is struct { x, y }; Point(1, 2) != Point(1, 3)", true},
+		{"jeff's Point is struct { x, y }; Point(1, 2.0) == Point(1.0, 2)", true},
+		{"jeff's Line is struct { a, b }; jeff's P is struct { x }; Line(P(1), P(2)) == Line(P(1), P(2))", true},
+		{"jeff's A is struct { x }; jeff's B is struct { x }; A(1) == B(1)", false},
+		{"jeff's Box is struct { items }; jeff's b is Box([1]); b == b", true},
+		{"jeff's Box is struct { items }; Box([1]) == Box([1])", true},
+		{"jeff's Box is struct { items }; Box(fn() { 1 }) == Box(fn() { 1 })", false},
+		{"jeff's Node is struct { next }; jeff's a is Node(0); jeff's b is Node(a); a.next is b; a == b", true},
+		{"jeff's Node is struct { value, next }; jeff's a is Node(1, 0); jeff's b is Node(2, a); a.next is b; a == b", false},
+		{"jeff's Node is struct { value, next }; jeff's a is Node(1, 0); a.next is a; jeff's b is Node(1, a); a == b", true},
+		{"jeff's Point is struct { x, y }; Point(1)", "wrong number of arguments. got=1, want=2"},
+		{"jeff's Point is struct { x, y }; Point(1, 2).z", "Point has no field z"},
+		{"jeff's Point is struct { x, y }; jeff's p is Point(1, 2); p.z is 1", "Point has no field z"},
+		{"jeff's Point is struct { x, y }; Point(1, 2) < Point(1, 2)", "unknown operator: STRUCT < STRUCT"},
+		{"jeff's x is 1; x.y is 2", "cannot assign to x.y, INTEGER is not a struct"},
 	}
 
 	for _, tt := range tests {
@@ -355,43 +774,790 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
-		case nil:
-			testNullObject(t, evaluated)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
 		case string:
 			errObj, ok := evaluated.(*object.ERROR)
 			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)",
-					evaluated, evaluated)
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
 				continue
 			}
 			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q",
-					expected, errObj.Message)
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
 			}
 		}
 	}
 }
 
-func testNullObject(t *testing.T, obj object.Object) bool {
+func TestStructInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"jeff's Point is struct { x, y }; Point(1, 2)", "Point{x: 1, y: 2}"},
+		{`jeff's Named is struct { name }; Named("jeff")`, "Named{name: jeff}"},
+		{"jeff's Point is struct { x, y }; Point", "struct Point { x, y }"},
+		{"struct { a }(1)", "{a: 1}"},
+		{"jeff's Node is struct { value, next }; jeff's a is Node(1, 0); a.next is a; a", "Node{value: 1, next: Node{...}}"},
+		{"jeff's Node is struct { next }; jeff's a is Node(0); jeff's b is Node([a]); a.next is b; a", "Node{next: Node{next: [Node{...}]}}"},
+	}
 
-	if obj != NULL {
-		t.Errorf("Object %T (%+v) is not null", obj, obj)
-		return false
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
 	}
-	return true
+}
+
+func TestImports(t *testing.T) {
+	dir := t.TempDir()
+	libDir := t.TempDir()
+	t.Setenv("JEFF_PATH", libDir)
+
+	writeFile(t, filepath.Join(dir, "utils.jeff"), `
+jeff's count is 0;
+jeff's add is fn(x, y) { x + y };
+jeff's double is fn(x) { helpers.twice(x) };
+import "helpers";`)
+	writeFile(t, filepath.Join(dir, "helpers.jeff"), `jeff's twice is fn(x) { x * 2 };`)
+	writeFile(t, filepath.Join(libDir, "shared.jeff"), `jeff's name is "shared";`)
+	writeFile(t, filepath.Join(dir, "a.jeff"), `import "b.jeff";`)
+	writeFile(t, filepath.Join(dir, "b.jeff"), `import "a.jeff";`)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "utils.jeff"; utils.add(1, 2)`, 3},
+		{`import "utils"; utils.double(4)`, 8},
+		{`import "shared"; shared.name`, "shared"},
+		{`import "utils"; utils.missing`, "module utils has no member missing"},
+		{`import "nope"`, `module not found: "nope"`},
+		{`jeff's x is 1; x.y`, "INTEGER has no method y"},
+		{`import "a"`, "import cycle: "},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(tt.input, filepath.Join(dir, "main.jeff"))
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.ERROR); ok {
+				if !strings.HasPrefix(errObj.Message, expected) {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
 
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
 }
 
-func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
+func TestImportCycleThroughTheEntryFile(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.jeff")
+	other := filepath.Join(dir, "other.jeff")
+	input := `import "other";`
+	writeFile(t, main, input)
+	writeFile(t, other, `import "main";`)
+
+	evaluated := testEvalFile(input, main)
+
+	errObj, ok := evaluated.(*object.ERROR)
 	if !ok {
-		t.Errorf("object %T (%+v) is not boolean", obj, obj)
-		return false
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	if result.Value != expected {
-		t.Errorf("object has wrong value. Expected %t, but got %t", expected, result.Value)
-		return false
+	expected := "import cycle: " + main + " -> " + other + " -> " + main
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestImportsAreCached(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "counter.jeff"), `jeff's count is 0; jeff's bump is fn() { count is count + 1 };`)
+
+	input := `import "counter"; counter.bump(); counter.bump();`
+	testEvalFile(input, filepath.Join(dir, "main.jeff"))
+	evaluated := testEvalFile(input+" counter.count", filepath.Join(dir, "other.jeff"))
+
+	testIntegerObject(t, evaluated, 4)
+}
+
+func writeFile(t *testing.T, path string, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJeffStatements(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"jeff's x is 5; x", 5},
+		{"jeff's a is 5 * 5;a;", 25},
+		{"jeff's a is 5; jeff's b is 5; jeff's c is a * b; c;", 25},
+	}
+
+	for _, testCase := range tests {
+		testIntegerObject(t, testEval(testCase.input), testCase.expected)
+	}
+
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"jeff's x is 0; while (x < 10) { jeff's x is x + 1; }; x", 10},
+		{"jeff's x is 0; while (huang) { jeff's x is x + 1; }; x", 0},
+		{"jeff's x is 0; while (right) { if (x > 4) { break; } jeff's x is x + 1; }; x", 5},
+		{`jeff's x is 0; jeff's total is 0;
+		while (x < 10) {
+			jeff's x is x + 1;
+			if (x > 5) { continue; }
+			jeff's total is total + x;
+		};
+		total`, 15},
+		{"jeff's f is fn() { jeff's x is 0; while (right) { jeff's x is x + 1; if (x > 2) { return x; } } }; f()", 3},
+		{`jeff's x is 0; jeff's count is 0;
+		while (x < 3) {
+			jeff's x is x + 1;
+			jeff's y is 0;
+			while (right) {
+				jeff's y is y + 1;
+				if (y > 2) { break; }
+				jeff's count is count + 1;
+			}
+		};
+		count`, 6},
+	}
+
+	for _, testCase := range tests {
+		testIntegerObject(t, testEval(testCase.input), testCase.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"jeff's x is 5; x is 6; x", 6},
+		{"jeff's x is 5; x is x + 1", 6},
+		{"jeff's x is 1; jeff's y is 1; x is y is 3; x + y", 6},
+		{"jeff's count is 0; jeff's inc is fn() { count is count + 1 }; inc(); inc(); count", 2},
+		{"jeff's x is 1; jeff's f is fn() { jeff's x is 10; x is 20; }; f(); x", 1},
+		{"jeff's x is 0; while (x < 10) { x is x + 1 }; x", 10},
+		// Inside a function a name means the outer variable until the function declares its own
+		{"jeff's x is 1; jeff's f is fn() { jeff's y is x; jeff's x is 10; y + x }; f()", 11},
+		{"jeff's x is 1; jeff's f is fn() { jeff's t is 0; jeff's i is 0; while (i < 2) { t is t + x; jeff's x is 10; i is i + 1 }; t }; f()", 2},
+		{"jeff's x is 1; jeff's f is fn() { x is 5; jeff's x is 10; x }; f() + x", 15},
+		{"jeff's f is fn() { jeff's g is fn() { x }; jeff's x is 3; g() }; f()", 3},
+		{"jeff's a is 1; jeff's f is fn(b is a, a is 2) { a + b }; f()", 3},
+		// A local whose jeff's never ran can't be assigned to
+		{"fn() { if (huang) { jeff's y is 1 }; y is 5; y }()", "identifier not found: y"},
+		{"fn() { jeff's f is fn() { y is 5 }; if (huang) { jeff's y is 1 }; f() }()", "identifier not found: y"},
+		{"fn() { jeff's f is fn() { y }; if (huang) { jeff's y is 1 }; y is 5; f() }()", "identifier not found: y"},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (err) { 2 }`, 1},
+		// Undefined names are found before the program runs, so they can't be caught
+		{`try { foobar } catch (err) { 2 }`, &object.ERROR{Message: "identifier not found: foobar"}},
+		{`try { foobar } catch (err) { err["message"] }`, &object.ERROR{Message: "identifier not found: foobar"}},
+		{`try { foobar } catch (err) { err["type"] }`, &object.ERROR{Message: "identifier not found: foobar"}},
+		{`try { 1 + huang } catch (err) { 2 }`, 2},
+		{`try { 1 + huang } catch (err) { err["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { 1 + huang } catch (err) { err["type"] }`, "RuntimeError"},
+		{`try { raise("nope") } catch (err) { err["message"] }`, "nope"},
+		{`try { raise("nope") } catch (err) { err["type"] }`, "Error"},
+		{`try { raise("nope", "ValueError") } catch (err) { err["type"] }`, "ValueError"},
+		{"try {\n  1 + huang\n} catch (err) { err[\"line\"] }", 2},
+		{"try {\n  1 + huang\n} catch (err) { err[\"column\"] }", 5},
+		{`jeff's f is fn() { raise("deep") }; try { f() } catch (err) { err["message"] }`, "deep"},
+		{`jeff's f is fn() { try { return 1; } catch (err) { 2 }; 3 }; f()`, 1},
+		{`try { 1; raise("stop"); 2 } catch (err) { 3 }`, 3},
+		// The parameter only exists in the handler, hiding any variable with the same name
+		{`jeff's err is 5; try { raise("x") } catch (err) { 0 }; err`, 5},
+		{`jeff's f is fn() { jeff's e is "mine"; try { 1 / 0 } catch (e) { 0 }; e }; f()`, "mine"},
+		{`try { raise("a") } catch (e) { try { raise("b") } catch (e) { 0 }; e["message"] }`, "a"},
+		{`fn() { try { raise("a") } catch (e) { try { raise("b") } catch (e) { 0 }; e["message"] } }()`, "a"},
+		{`fn() { jeff's g is try { raise("a") } catch (e) { fn() { e["message"] } }; g() }()`, "a"},
+		{`try { raise("x") } catch (err) { 0 }; err`, &object.ERROR{Message: "identifier not found: err"}},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		case *object.ERROR:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected.Message {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected.Message, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) {  x + 2 ; };"
+
+	evaluated := testEval(input)
+
+	var params []*ast.Indentifier
+	var body *ast.BlockStatement
+
+	// The vm's functions are closures over the compiled function, which keeps its literal
+	switch fn := evaluated.(type) {
+	case *object.Function:
+		params, body = fn.Parameters, fn.Body
+	case *object.Closure:
+		params, body = fn.Fn.Literal.Parameters, fn.Fn.Literal.Body
+	default:
+		t.Fatalf("Evaluated %T(%+v) could not be converted to function", evaluated, evaluated)
+	}
+
+	if len(params) != 1 {
+		t.Fatalf("Expected lengh of parameters to be 1, but got %d", len(params))
+	}
+
+	if params[0].String() != "x" {
+		t.Fatalf("Expected function parameter to be x but got %s", params[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if body.String() != expectedBody {
+		t.Fatalf("Expected function body to be %s but got %s", expectedBody, body)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"jeff's identity is fn(x) { x; }; identity(5);", 5},
+		{"jeff's identity is fn(x) { return x; }; identity(5);", 5},
+		{"jeff's double is fn(x) { x * 2; }; double(5);", 10},
+		{"jeff's add is fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"jeff's add is fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, testCase := range tests {
+		testIntegerObject(t, testEval(testCase.input), testCase.expected)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"jeff's f is fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000000)", 0},
+		{"jeff's f is fn(n, total) { if (n == 0) { return total }; return f(n - 1, total + n) }; f(100000, 0)", 5000050000},
+		{"jeff's even is fn(n) { if (n == 0) { right } else { odd(n - 1) } }; jeff's odd is fn(n) { if (n == 0) { huang } else { even(n - 1) } }; even(100001)", false},
+		{"jeff's f is fn(n) { while (right) { if (n > 5) { return len([n]) }; n is n + 1 } }; f(0)", 1},
+		{"jeff's f is fn() { try { 1 + huang } catch (e) { fn(x) { x }(2) } }; f()", 2},
+		{"jeff's f is fn(n) { if (n == 0) { raise(\"done\") } else { f(n - 1) } }; try { f(100000) } catch (e) { e[\"message\"] }", "done"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTailCallStackTrace(t *testing.T) {
+	input := `jeff's countdown is fn(n) {
+  if (n == 0) { 1 + huang } else { countdown(n - 1) }
+};
+countdown(150);`
+
+	errObj, ok := testEval(input).(*object.ERROR)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	// Only the most recent tail calls are kept
+	if len(errObj.Stack) != object.MaxTailCalls+1 {
+		t.Errorf("wrong stack length. expected=%d, got=%d", object.MaxTailCalls+1, len(errObj.Stack))
+	}
+
+	for _, frame := range errObj.Stack {
+		if frame.Function != "countdown" {
+			t.Errorf("wrong function in stack. expected=countdown, got=%s", frame.Function)
+		}
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"jeff's add is fn(x, y is 10) { x + y }; add(1)", 11},
+		{"jeff's add is fn(x, y is 10) { x + y }; add(1, 2)", 3},
+		{"jeff's f is fn(x, y is x * 2) { y }; f(4)", 8},
+		{"jeff's f is fn(x is null) { x }; f()", nil},
+		{"jeff's count is fn(...rest) { len(rest) }; count()", 0},
+		{"jeff's count is fn(...rest) { len(rest) }; count(1, 2, 3)", 3},
+		{"jeff's f is fn(first, ...rest) { rest }; f(1, 2, 3)", []int{2, 3}},
+		{"jeff's f is fn(a, b is 5, ...rest) { a + b + len(rest) }; f(1)", 6},
+		{"jeff's f is fn(a, b is 5, ...rest) { a + b + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"jeff's add is fn(x, y) { x + y }; add(1)", "wrong number of arguments to add. got=1, want=2"},
+		{"jeff's add is fn(x, y) { x + y }; add(1, 2, 3)", "wrong number of arguments to add. got=3, want=2"},
+		{"fn() { 1 }(1)", "wrong number of arguments to <fn>. got=1, want=0"},
+		{"jeff's f is fn(x, y is 1) { x }; f()", "wrong number of arguments to f. got=0, want=1 to 2"},
+		{"jeff's f is fn(x, ...rest) { x }; f()", "wrong number of arguments to f. got=0, want at least 1"},
+		{"jeff's f is fn(x is 1 + right) { x }; f()", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("wrong array for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			for i, element := range expected {
+				testIntegerObject(t, array.Elements[i], int64(element))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "ab"`, "abab"},
+		{`"ab" * 0`, ""},
+		{`"jeff"[0]`, "j"},
+		{`"jeff"[3]`, "f"},
+		{`"jeff"[4]`, nil},
+		{`"jeff"[-1]`, nil},
+		{`"jeff"[1:3]`, "ef"},
+		{`"jeff"[:2]`, "je"},
+		{`"jeff"[2:]`, "ff"},
+		{`"jeff"[:]`, "jeff"},
+		{`"jeff"[1:100]`, "eff"},
+		{`"jeff"[3:1]`, ""},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`"日本語"[3]`, nil},
+		{`"héllo"[1:3]`, "él"},
+		{`jeff's café is "☕"; café`, "☕"},
+		{`jeff's name is "jeff"; "hello ${name}!"`, "hello jeff!"},
+		{`jeff's x is 2; "${x} + ${x} is ${x + x}"`, "2 + 2 is 4"},
+		{`"${[1, 2]} ${right}"`, "[1, 2] right"},
+		{`jeff's h is {"a": "b"}; "${h["a"]}"`, "b"},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`jeff's x is 1; "\${x} is ${x}"`, "${x} is 1"},
+		{`"say \"${"hi"}\""`, "say \"hi\""},
+		{"\"\"\"one\ntwo \\n ${x}\"\"\"", "one\ntwo \\n ${x}"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{`"ab" * -1`, "negative string repeat count: -1"},
+		{`"jeff"["a":]`, "slice index must be INTEGER, got STRING"},
+		{`"${foobar}"`, "identifier not found: foobar"},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+
+		switch expected := testCase.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.ERROR); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		}
+	}
+}
+
+func TestArraySlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int64
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:1]", []int64{1}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][5:]", []int64{}},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if len(array.Elements) != len(testCase.expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d", len(testCase.expected), len(array.Elements))
+			continue
+		}
+
+		for i, expected := range testCase.expected {
+			testIntegerObject(t, array.Elements[i], expected)
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本")`, 2},
+		{`bytes("hé")`, []int{104, 195, 169}},
+		{`len(bytes("日本"))`, 6},
+		{`bytes(1)`, "argument to `bytes` must be STRING, got INTEGER"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`help(1)`, "argument to `help` must be FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d",
+					len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"jeff's i is 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"jeff's myArray is [1, 2, 3]; myArray[2];", 3},
+		{"jeff's myArray is [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"jeff's myArray is [1, 2, 3]; jeff's i is myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+		integer, ok := testCase.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `jeff's two is "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		right: 5,
+		huang: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		RIGHT.HashKey():                            5,
+		HUANG.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, "{}"},
+		{`{"a": 1, "b": 2, "c": 3, "d": 4}`, "{a: 1, b: 2, c: 3, d: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`{3: [1], right: {"x": huang}}`, "{3: [1], right: {x: huang}}"},
+		{`try { raise("x") } catch (err) { err }`, "{message: x, type: Error, file: , line: 1, column: 12}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`jeff's key is "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{right: 5}[right]`, 5},
+		{`{huang: 5}[huang]`, 5},
+		{`{"foo": 1, "foo": 2}["foo"]`, 2},
+		{`jeff's n is 0; jeff's next is fn() { n is n + 1 }; {"a": next(), "b": next(), "c": next()}["b"]`, 2},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testEval(testCase.input)
+		integer, ok := testCase.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+
+	if obj != NULL {
+		t.Errorf("Object %T (%+v) is not null", obj, obj)
+		return false
+	}
+	return true
+
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object %T (%+v) is not boolean", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. Expected %t, but got %t", expected, result.Value)
+		return false
 	}
 
 	return true
@@ -403,7 +1569,32 @@ func testEval(input string) object.Object {
 	program := parser.ParseProgram()
 	env := object.NewEnvironment()
 
-	return Eval(program, env)
+	return engine.eval(program, env)
+}
+
+// testEvalFile evaluates input as if it was read from the file at path
+func testEvalFile(input string, path string) object.Object {
+	lexer := lexer.NewWithFile(input, path)
+	parser := parser.New(lexer)
+	program := parser.ParseProgram()
+	env := object.NewEnvironment()
+
+	return engine.evalFile(path, program, env)
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object %T (%+v) is not float", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. Expected %g, but got %g", expected, result.Value)
+		return false
+	}
+
+	return true
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
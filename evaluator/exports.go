@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"jeff/ast"
+	"jeff/object"
+)
+
+// The vm reuses the evaluator's operations so both engines give the same results and errors
+
+// Resolve checks the program for identifiers that aren't declared anywhere, as the
+// evaluator does before running a program
+func Resolve(program *ast.Program, env *object.Environment) *object.ERROR {
+	return resolve(program, env)
+}
+
+// Builtin returns the builtin function with the name, if there is one
+func Builtin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Integer returns an integer object, sharing the small ones like the evaluator does
+func Integer(value int64) *object.Integer {
+	return newInteger(value)
+}
+
+// IsTruthy reports whether a value counts as true in a condition
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Infix applies an infix operator (other than and, or and ??) to two values
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Prefix applies a prefix operator to a value
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Index returns left[index]
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Slice returns left[start:end]. start and end are nil if they were left out
+func Slice(left, start, end object.Object) object.Object {
+	return sliceObject(left, start, end)
+}
+
+// Member returns left.name
+func Member(left object.Object, name string) object.Object {
+	return evalMemberExpression(left, name)
+}
+
+// SetField does left.name is val. target is the source of left.name, for errors
+func SetField(left object.Object, name string, val object.Object, target string) object.Object {
+	return setField(left, name, val, target)
+}
+
+// NewStructInstance calls a struct type with the arguments
+func NewStructInstance(structType *object.StructType, args []object.Object) object.Object {
+	return newStructInstance(structType, args)
+}
+
+// Name gives an unnamed function or struct the name it is being bound to with jeff's
+func Name(val object.Object, name string, doc string) {
+	nameObject(val, name, doc)
+}
+
+// ArityError returns an error if a function can't be called with count arguments
+func ArityError(name string, count int, required int, params int, variadic bool) *object.ERROR {
+	return arityError(name, count, required, params, variadic)
+}
+
+// ErrorToHash converts an error into the hash a catch parameter is bound to
+func ErrorToHash(err *object.ERROR) *object.Hash {
+	return errorToHash(err)
+}
+
+// NewError creates an error with a formatted message
+func NewError(format string, a ...interface{}) *object.ERROR {
+	return newError(format, a...)
+}
@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"jeff/object"
+	"math"
+	"strings"
+)
+
+// method is a function called on a value with a . i.e. "abc".upper()
+// The value the method was called on is passed in as the receiver
+type method func(receiver object.Object, args ...object.Object) object.Object
+
+// Methods on the built in types, by the type of the receiver
+var methods = map[object.ObjectType]map[string]method{
+	object.STRING_OBJ: {
+		"upper": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.String{Value: strings.ToUpper(receiver.(*object.String).Value)}
+		},
+		"lower": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.String{Value: strings.ToLower(receiver.(*object.String).Value)}
+		},
+		// trim removes whitespace from both ends
+		"trim": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.String{Value: strings.TrimSpace(receiver.(*object.String).Value)}
+		},
+		// split breaks the string up around each separator. e.g. "a,b".split(",") is ["a", "b"]
+		"split": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			separator, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `split` must be STRING, got %s", args[0].Type())
+			}
+
+			parts := strings.Split(receiver.(*object.String).Value, separator.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+		"contains": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			substring, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `contains` must be STRING, got %s", args[0].Type())
+			}
+
+			return nativeBoolToBooleanObject(strings.Contains(receiver.(*object.String).Value, substring.Value))
+		},
+		// replace swaps every occurrence of the first argument for the second
+		"replace": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			old, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `replace` must be STRING, got %s", args[0].Type())
+			}
+			replacement, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `replace` must be STRING, got %s", args[1].Type())
+			}
+
+			return &object.String{Value: strings.ReplaceAll(receiver.(*object.String).Value, old.Value, replacement.Value)}
+		},
+	},
+	object.INTEGER_OBJ: {
+		"abs": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			value := receiver.(*object.Integer).Value
+			if value >= 0 {
+				return receiver
+			}
+			if value == math.MinInt64 && CheckedArithmetic {
+				return newError("integer overflow: abs(%d)", value)
+			}
+			return newInteger(-value)
+		},
+	},
+	object.FLOAT_OBJ: {
+		"abs": floatMethod(math.Abs),
+		// floor, ceil and round give back a float. Use int() to get an integer
+		"floor": floatMethod(math.Floor),
+		"ceil":  floatMethod(math.Ceil),
+		"round": floatMethod(math.Round),
+	},
+	object.ARRAY_OBJ: {
+		// contains reports whether any element is == to the argument
+		"contains": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			for _, element := range receiver.(*object.Array).Elements {
+				if objectsEqual(element, args[0]) {
+					return RIGHT
+				}
+			}
+
+			return HUANG
+		},
+		// join puts the elements together into one string with the separator between each
+		"join": func(receiver object.Object, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			separator, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `join` must be STRING, got %s", args[0].Type())
+			}
+
+			elements := receiver.(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, element := range elements {
+				parts[i] = element.Inspect()
+			}
+
+			return &object.String{Value: strings.Join(parts, separator.Value)}
+		},
+	},
+}
+
+// floatMethod makes a method that takes no arguments from a float function
+func floatMethod(fn func(float64) float64) method {
+	return func(receiver object.Object, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+		return &object.Float{Value: fn(receiver.(*object.Float).Value)}
+	}
+}
+
+// evalMemberExpression gets a top level binding out of a module, a field of a struct,
+// or a method bound to the value it was accessed on
+func evalMemberExpression(left object.Object, name string) object.Object {
+	if module, ok := left.(*object.Module); ok {
+		val, ok := module.Env.Get(name)
+		if !ok {
+			return newError("module %s has no member %s", module.Name, name)
+		}
+		return val
+	}
+
+	if instance, ok := left.(*object.StructInstance); ok {
+		val, ok := instance.Fields[name]
+		if !ok {
+			return newError("%s has no field %s", structName(instance.StructType), name)
+		}
+		return val
+	}
+
+	fn, ok := methods[left.Type()][name]
+	if !ok {
+		return newError("%s has no method %s", left.Type(), name)
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return fn(left, args...)
+	}}
+}
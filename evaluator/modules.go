@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"jeff/ast"
+	"jeff/lexer"
+	"jeff/object"
+	"jeff/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleRunner runs a parsed module in its environment
+type ModuleRunner func(program *ast.Program, env *object.Environment) object.Object
+
+// ModuleLoader loads modules and caches them. Each engine has its own loader,
+// so a module is run by the same engine as the code that imports it
+type ModuleLoader struct {
+	// Modules that have finished loading, by absolute path. Each file is only run once
+	modules map[string]*object.Module
+	// Absolute paths of the modules currently being loaded, outermost first. Used to find import cycles
+	loading []string
+}
+
+func NewModuleLoader() *ModuleLoader {
+	return &ModuleLoader{modules: map[string]*object.Module{}}
+}
+
+// The evaluator's modules
+var modules = NewModuleLoader()
+
+// EvalFile runs a program read from the file at path as the entry point of the evaluator,
+// so imports that lead back to the file are found as a cycle
+func EvalFile(path string, program *ast.Program, env *object.Environment) object.Object {
+	return modules.Run(path, program, env, func(program *ast.Program, env *object.Environment) object.Object {
+		return Eval(program, env)
+	})
+}
+
+// evalImportStatement loads the module and binds it to its name in the environment
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := modules.Import(node.Path, node.Token.Position.File, node.Name.Value, func(program *ast.Program, env *object.Environment) object.Object {
+		return Eval(program, env)
+	})
+	if isError(module) {
+		return module
+	}
+
+	env.Define(node.Name.Slot, node.Name.Value, module)
+	return nil
+}
+
+// Import finds the module that importer (the file doing the import) refers to with path,
+// and loads it with run unless it has already been loaded
+func (l *ModuleLoader) Import(path string, importer string, name string, run ModuleRunner) object.Object {
+	resolved, ok := resolveModulePath(path, importer)
+	if !ok {
+		return newError("module not found: %q", path)
+	}
+
+	return l.load(name, resolved, run)
+}
+
+// Run runs the program of the file at path with run, counting the file as being loaded
+// while it runs. Used for the file a program starts from, which isn't imported by anything
+func (l *ModuleLoader) Run(path string, program *ast.Program, env *object.Environment, run ModuleRunner) object.Object {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return newError("file %s can't be found", path)
+	}
+
+	l.loading = append(l.loading, absolute)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	return run(program, env)
+}
+
+// resolveModulePath finds the file an import refers to. Relative paths are looked for next to the
+// importing file (or the working directory in the REPL) and then in each directory of JEFF_PATH.
+// The .jeff extension is optional
+func resolveModulePath(path string, importer string) (string, bool) {
+	if !strings.HasSuffix(path, ".jeff") {
+		path += ".jeff"
+	}
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(filepath.Dir(importer), path)}
+		for _, dir := range filepath.SplitList(os.Getenv("JEFF_PATH")) {
+			if dir != "" {
+				candidates = append(candidates, filepath.Join(dir, path))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			absolute, err := filepath.Abs(candidate)
+			if err != nil {
+				return "", false
+			}
+			return absolute, true
+		}
+	}
+
+	return "", false
+}
+
+// load runs the file at path in a new environment. Returns the cached module if
+// it has already been loaded, or an error if it is part of an import cycle
+func (l *ModuleLoader) load(name string, path string, run ModuleRunner) object.Object {
+	if module, ok := l.modules[path]; ok {
+		return module
+	}
+
+	for i, loadingPath := range l.loading {
+		if loadingPath == path {
+			cycle := append(append([]string{}, l.loading[i:]...), path)
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return newError("module %s can't be read", path)
+	}
+
+	p := parser.New(lexer.NewWithFile(string(data), path))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("module %s has parser errors:\n%s", path, strings.Join(p.Errors(), "\n"))
+	}
+
+	l.loading = append(l.loading, path)
+	env := object.NewEnvironment()
+	result := run(program, env)
+	l.loading = l.loading[:len(l.loading)-1]
+
+	if isError(result) {
+		return result
+	}
+
+	module := &object.Module{Name: name, Path: path, Env: env}
+	l.modules[path] = module
+	return module
+}
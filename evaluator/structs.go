@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"jeff/ast"
+	"jeff/object"
+)
+
+// newStructInstance creates an instance of the struct type. Arguments fill the fields in the order they were declared
+func newStructInstance(structType *object.StructType, args []object.Object) object.Object {
+	if len(args) != len(structType.Fields) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(structType.Fields))
+	}
+
+	fields := make(map[string]object.Object, len(args))
+	for i, name := range structType.Fields {
+		fields[name] = args[i]
+	}
+
+	return &object.StructInstance{StructType: structType, Fields: fields}
+}
+
+// evalFieldAssignExpression updates a field of a struct instance. Every reference to the instance sees the change
+func evalFieldAssignExpression(node *ast.FieldAssignExpression, env *object.Environment) object.Object {
+	left := Eval(node.Target.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return setField(left, node.Target.Property.Value, val, node.Target.String())
+}
+
+// setField sets the named field of left, which must be a struct instance with that field.
+// target is the expression being assigned to, for errors
+func setField(left object.Object, name string, val object.Object, target string) object.Object {
+	instance, ok := left.(*object.StructInstance)
+	if !ok {
+		return newError("cannot assign to %s, %s is not a struct", target, left.Type())
+	}
+
+	if _, ok := instance.Fields[name]; !ok {
+		return newError("%s has no field %s", structName(instance.StructType), name)
+	}
+
+	instance.Fields[name] = val
+	return val
+}
+
+// structName gives anonymous structs a name to show in errors
+func structName(structType *object.StructType) string {
+	if structType.Name == "" {
+		return "struct"
+	}
+	return structType.Name
+}
@@ -1,44 +1,87 @@
 package lexer
 
 import (
+	"fmt"
 	"jeff/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Lexer. Converts characters to tokens
+// Lexer. Converts characters to tokens.
+// The input is read as UTF-8, so a character is a whole rune and positions are byte offsets
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	character    byte
+	character    rune
+
+	// Where the current character is in the source. Used to give tokens a position
+	file   string
+	line   int
+	column int
+
+	// Doc comment found while skipping whitespace before the current token
+	doc string
+	// Where a /* that is never closed started. It swallows the rest of the input
+	unterminatedComment *token.Position
 }
 
 // Constructor for the lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFile(input, "")
+}
+
+// NewWithFile creates a lexer whose tokens will report the file they came from
+func NewWithFile(input string, file string) *Lexer {
+	return NewAt(input, token.Position{File: file, Line: 1, Column: 1})
+}
+
+// NewAt creates a lexer for input that starts part way through a source.
+// Used for code embedded in other tokens, like the expressions in interpolated strings
+func NewAt(input string, start token.Position) *Lexer {
+	l := &Lexer{input: input, file: start.File, line: start.Line, column: start.Column - 1}
 	l.readChar()
 	return l
 }
 
 // Set the next character in the input as the current character. Then moves position pointers
-// If input is at end will set character to 0
+// If input is at end will set character to 0. Invalid UTF-8 is read one byte at a time as utf8.RuneError
 func (l *Lexer) readChar() {
+	// Moving past a new line means the next character starts a new line
+	if l.character == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	size := 1
 	if l.readPosition >= len(l.input) {
 		l.character = 0
 	} else {
-		l.character = l.input[l.readPosition]
+		l.character, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
 }
 
 // newToken creates a new token of the given type and character
-func newToken(tokenType token.TokenType, character byte) token.Token {
+func newToken(tokenType token.TokenType, character rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(character),
 	}
 }
 
+// newTwoCharToken creates a token from the current and next character.
+// Leaves the lexer on the second character
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	currentChar := l.character
+	l.readChar()
+	return token.Token{Type: tokenType, Literal: string(currentChar) + string(l.character)}
+}
+
 // readIdentifier reads the next whole word from the input
 func (l *Lexer) readIdentifier() string {
 	position := l.position
@@ -49,22 +92,62 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// readNumber reads the next integer from the input
-func (l *Lexer) readNumber() string {
+// readNumber reads the next integer or float from the input.
+// Floats have a decimal part and/or an exponent. e.g. 3.14, 1e10, 2.5E-3
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	var tokenType token.TokenType = token.INT
+
+	l.readDigits()
+
+	// A . not followed by a digit isn't part of the number
+	if l.character == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.character == 'e' || l.character == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekCharAt(2)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.character == '+' || l.character == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.character) {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// currentPosition returns the position of the current character
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{File: l.file, Line: l.line, Column: l.column}
 }
 
-// NextToken creates a token from the next set of characters (ignoring whitespace)
+// NextToken creates a token from the next set of characters (ignoring whitespace and comments)
 func (l *Lexer) NextToken() token.Token {
 	var t token.Token
 
 	l.skipWhitespace()
 
+	if l.unterminatedComment != nil {
+		t = token.Token{Type: token.ILLEGAL, Literal: "unterminated block comment", Position: *l.unterminatedComment}
+		l.unterminatedComment = nil
+		return t
+	}
+
+	position := l.currentPosition()
+	doc := l.doc
+
 	switch l.character {
 	case '=':
 		if l.peekChar() == '=' {
@@ -89,17 +172,51 @@ func (l *Lexer) NextToken() token.Token {
 			t = newToken(token.BANG, l.character)
 		}
 	case '*':
-		t = newToken(token.ASTERIX, l.character)
+		if l.peekChar() == '*' {
+			t = l.newTwoCharToken(token.POWER)
+		} else {
+			t = newToken(token.ASTERIX, l.character)
+		}
 	case '/':
-		t = newToken(token.SLASH, l.character)
+		if l.peekChar() == '/' {
+			t = l.newTwoCharToken(token.DOUBLE_SLASH)
+		} else {
+			t = newToken(token.SLASH, l.character)
+		}
+	case '%':
+		t = newToken(token.MODULO, l.character)
 	case '<':
-		t = newToken(token.LT, l.character)
+		if l.peekChar() == '=' {
+			t = l.newTwoCharToken(token.LT_EQUALS)
+		} else {
+			t = newToken(token.LT, l.character)
+		}
 	case '>':
-		t = newToken(token.GT, l.character)
+		if l.peekChar() == '=' {
+			t = l.newTwoCharToken(token.GT_EQUALS)
+		} else {
+			t = newToken(token.GT, l.character)
+		}
 	case ',':
 		t = newToken(token.COMMA, l.character)
 	case ';':
 		t = newToken(token.SEMICOLON, l.character)
+	case ':':
+		t = newToken(token.COLON, l.character)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			t = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			t = newToken(token.DOT, l.character)
+		}
+	case '?':
+		if l.peekChar() == '?' {
+			t = l.newTwoCharToken(token.COALESCE)
+		} else {
+			t = newToken(token.ILLEGAL, l.character)
+		}
 	case '(':
 		t = newToken(token.LPAREN, l.character)
 	case ')':
@@ -108,9 +225,31 @@ func (l *Lexer) NextToken() token.Token {
 		t = newToken(token.LBRACE, l.character)
 	case '}':
 		t = newToken(token.RBRACE, l.character)
+	case '[':
+		t = newToken(token.LBRACKET, l.character)
+	case ']':
+		t = newToken(token.RBRACKET, l.character)
 	case '"':
-		t.Type = token.STRING
-		t.Literal = l.readString()
+		if l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			literal, ok := l.readRawString()
+			if ok {
+				t.Type = token.RAW_STRING
+				t.Literal = literal
+			} else {
+				t.Type = token.ILLEGAL
+				t.Literal = "unterminated raw string"
+			}
+		} else {
+			literal, problem := l.readString()
+			if problem == "" {
+				t.Type = token.STRING
+				t.Literal = literal
+			} else {
+				// Illegal string tokens carry a description of the problem as their literal
+				t.Type = token.ILLEGAL
+				t.Literal = problem
+			}
+		}
 
 	case 0:
 		t.Literal = ""
@@ -120,50 +259,290 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.character) {
 			t.Literal = l.readIdentifier()
 			t.Type = token.LookupIdentifier(t.Literal)
+			t.Position = position
+			t.Doc = doc
 			return t
 		} else if isDigit(l.character) {
-			t.Literal = l.readNumber()
-			t.Type = token.INT
+			t.Literal, t.Type = l.readNumber()
+			t.Position = position
+			t.Doc = doc
 			return t
 		} else {
 			t = newToken(token.ILLEGAL, l.character)
 		}
 	}
 
+	t.Position = position
+	t.Doc = doc
 	l.readChar()
 	return t
 
 }
 
+// skipWhitespace moves past whitespace and comments to the start of the next token.
+// # comment lines directly above the token (with no blank line between) are saved as its doc comment.
+// Comments at the end of a line of code are never doc comments
 func (l *Lexer) skipWhitespace() {
-	for l.character == ' ' || l.character == '\t' || l.character == '\n' || l.character == '\r' {
+	var doc []string
+
+	// The number of new lines since the last comment. The start of the input counts as a new line
+	newLines := 0
+	if l.position == 0 {
+		newLines = 1
+	}
+
+	for {
+		switch {
+		case l.character == '\n':
+			newLines += 1
+			if newLines > 1 {
+				doc = nil
+			}
+			l.readChar()
+		case l.character == ' ' || l.character == '\t' || l.character == '\r':
+			l.readChar()
+		case l.character == '#':
+			comment := l.readLineComment()
+			if newLines == 0 {
+				doc = nil
+			} else {
+				doc = append(doc, comment)
+			}
+			newLines = 0
+		case l.character == '/' && l.peekChar() == '*':
+			start := l.currentPosition()
+			if !l.skipBlockComment() {
+				l.unterminatedComment = &start
+			}
+			doc = nil
+		default:
+			l.doc = strings.Join(doc, "\n")
+			return
+		}
+	}
+}
+
+// readLineComment reads a # comment up to the end of the line.
+// Returns the text of the comment without the # and the space after it
+func (l *Lexer) readLineComment() string {
+	position := l.position + 1
+	for l.character != '\n' && l.character != 0 {
 		l.readChar()
 	}
+
+	comment := strings.TrimSuffix(l.input[position:l.position], "\r")
+	return strings.TrimPrefix(comment, " ")
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition < len(l.input) {
-		return l.input[l.readPosition]
-	} else {
+// skipBlockComment moves past a /* block comment */.
+// Returns false if the input ends before the comment is closed
+func (l *Lexer) skipBlockComment() bool {
+	// Move past the opening /* so /*/ isn't a whole comment
+	l.readChar()
+	l.readChar()
+
+	for !(l.character == '*' && l.peekChar() == '/') {
+		if l.character == 0 {
+			return false
+		}
+		l.readChar()
+	}
+
+	l.readChar()
+	l.readChar()
+	return true
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(1)
+}
+
+// peekCharAt returns the character offset places after the current one without moving.
+// Returns 0 if that is past the end of the input
+func (l *Lexer) peekCharAt(offset int) rune {
+	index := l.readPosition
+	for i := 1; i < offset && index < len(l.input); i++ {
+		_, size := utf8.DecodeRuneInString(l.input[index:])
+		index += size
+	}
+
+	if index >= len(l.input) {
 		return 0
 	}
+
+	character, _ := utf8.DecodeRuneInString(l.input[index:])
+	return character
+}
+
+// readString reads a string up to its closing quote. The literal is the raw source
+// between the quotes, escape sequences are left in to be converted by Unescape.
+// If the string is unterminated or has an unknown escape sequence a description of
+// the problem is returned as well
+func (l *Lexer) readString() (string, string) {
+	position := l.position + 1
+	problem := l.skipStringBody()
+	return l.input[position:l.position], problem
 }
 
-func (l *Lexer) readString() string {
+// readRawString reads a triple quoted string. Raw strings can go over multiple lines
+// and have no escape sequences or interpolation. Returns false if it's unterminated
+func (l *Lexer) readRawString() (string, bool) {
+	// Move onto the third opening quote
+	l.readChar()
+	l.readChar()
+
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.character == '"' || l.character == 0 {
-			break
+		if l.character == 0 {
+			return "", false
+		}
+		if l.character == '"' && l.peekChar() == '"' && l.peekCharAt(2) == '"' {
+			literal := l.input[position:l.position]
+			// Leave the lexer on the last closing quote
+			l.readChar()
+			l.readChar()
+			return literal, true
 		}
 	}
-	return l.input[position:l.position]
 }
 
-func isLetter(characer byte) bool {
-	return 'a' <= characer && characer <= 'z' || 'A' <= characer && characer <= 'Z' || characer == '_' || characer == '\''
+// skipStringBody moves to the closing quote of the string (or the end of input).
+// Interpolated ${expressions} are skipped as a whole, so they can contain strings of their own.
+// Returns a description of the first problem found, or an empty string
+func (l *Lexer) skipStringBody() string {
+	problem := ""
+	for {
+		l.readChar()
+		switch {
+		case l.character == 0:
+			return "unterminated string"
+		case l.character == '"':
+			return problem
+		case l.character == '\\':
+			if escapeProblem := l.skipEscape(); escapeProblem != "" && problem == "" {
+				problem = escapeProblem
+			}
+		case l.character == '$' && l.peekChar() == '{':
+			l.readChar()
+			l.skipInterpolation()
+			if l.character == 0 {
+				return "unterminated ${ in string"
+			}
+		}
+	}
+}
+
+// skipEscape moves from the \ of an escape sequence to its last character.
+// Returns a description of the problem if it isn't a known escape sequence
+func (l *Lexer) skipEscape() string {
+	switch l.peekChar() {
+	case 'n', 't', 'r', '"', '\\', '$':
+		l.readChar()
+		return ""
+	case 'u':
+		// Unicode escapes look like \u{1F600}
+		l.readChar()
+		if l.peekChar() != '{' {
+			return "invalid unicode escape, expected \\u{...}"
+		}
+		l.readChar()
+
+		position := l.position + 1
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		hex := l.input[position : l.position+1]
+
+		if l.peekChar() != '}' {
+			return "invalid unicode escape, expected \\u{...}"
+		}
+		l.readChar()
+
+		value, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(value)) {
+			return fmt.Sprintf("invalid unicode code point \\u{%s}", hex)
+		}
+		return ""
+	case 0:
+		return "unterminated string"
+	default:
+		l.readChar()
+		return fmt.Sprintf("unknown escape sequence \\%c", l.character)
+	}
+}
+
+// Unescape converts the escape sequences in a raw string literal into the characters they stand for.
+// Expects the escapes to have already been checked by the lexer
+func Unescape(raw string) string {
+	if !strings.Contains(raw, "\\") {
+		return raw
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' || i+1 == len(raw) {
+			out.WriteByte(raw[i])
+			continue
+		}
+
+		i += 1
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case 'u':
+			end := strings.IndexByte(raw[i:], '}')
+			value, _ := strconv.ParseUint(raw[i+2:i+end], 16, 32)
+			out.WriteRune(rune(value))
+			i += end
+		default:
+			// \", \\ and \$ are just the character itself
+			out.WriteByte(raw[i])
+		}
+	}
+
+	return out.String()
+}
+
+// skipInterpolation moves from the { of a ${ to the } that closes it
+func (l *Lexer) skipInterpolation() {
+	depth := 0
+	for {
+		l.readChar()
+		switch l.character {
+		case 0:
+			return
+		case '"':
+			// Any problems with strings in here are reported when the expression is parsed
+			l.skipStringBody()
+			if l.character == 0 {
+				return
+			}
+		case '{':
+			depth += 1
+		case '}':
+			if depth == 0 {
+				return
+			}
+			depth -= 1
+		}
+	}
+}
+
+// isLetter reports whether the character can be part of an identifier.
+// Any unicode letter is allowed, so names like café or 名前 work
+func isLetter(characer rune) bool {
+	return unicode.IsLetter(characer) || characer == '_' || characer == '\''
+}
+
+func isHexDigit(char rune) bool {
+	return isDigit(char) || 'a' <= char && char <= 'f' || 'A' <= char && char <= 'F'
 }
 
-func isDigit(char byte) bool {
+func isDigit(char rune) bool {
 	return '0' <= char && char <= '9'
 }
@@ -12,7 +12,7 @@ jeff's ten is 10;
 jeff's add is fn(x, y) {
 	x + y;
 };
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 jeff's result is add(five, ten);
@@ -23,6 +23,17 @@ if(x != 3)
 
 "foobar"
 "foo bar"
+[1, 2];
+{"foo": "bar"}
+while break continue
+try catch
+3.14 1e10 2.5E-3 7.x
+% ** // <= >=
+and or
+import utils.add
+a ?? null ?
+...rest
+"a ${b["c"]} d"
 `
 
 	tests := []struct {
@@ -96,6 +107,46 @@ if(x != 3)
 		{token.RPAREN, ")"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.WHILE, "while"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.TRY, "try"},
+		{token.CATCH, "catch"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.MODULO, "%"},
+		{token.POWER, "**"},
+		{token.DOUBLE_SLASH, "//"},
+		{token.LT_EQUALS, "<="},
+		{token.GT_EQUALS, ">="},
+		{token.AND, "and"},
+		{token.OR, "or"},
+		{token.IMPORT, "import"},
+		{token.IDENT, "utils"},
+		{token.DOT, "."},
+		{token.IDENT, "add"},
+		{token.IDENT, "a"},
+		{token.COALESCE, "??"},
+		{token.NULL, "null"},
+		{token.ILLEGAL, "?"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.STRING, `a ${b["c"]} d`},
 		{token.EOF, ""},
 	}
 
@@ -112,3 +163,232 @@ if(x != 3)
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `jeff's x is 5;
+  x + "two
+lines";
+y`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"jeff's", 1, 1},
+		{"x", 1, 8},
+		{"is", 1, 10},
+		{"5", 1, 13},
+		{";", 1, 14},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"two\nlines", 2, 7},
+		{";", 3, 7},
+		{"y", 4, 1},
+		{"", 4, 2},
+	}
+
+	lexer := NewWithFile(input, "test.jeff")
+
+	for i, testCase := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Literal != testCase.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, testCase.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position.Line != testCase.expectedLine || tok.Position.Column != testCase.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong, expected=%d:%d, got=%d:%d", i,
+				testCase.expectedLine, testCase.expectedColumn, tok.Position.Line, tok.Position.Column)
+		}
+
+		if tok.Position.File != "test.jeff" {
+			t.Fatalf("tests[%d] - file wrong, expected=%q, got=%q", i, "test.jeff", tok.Position.File)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := `"a\n\"b\"" """raw \n "quoted"
+text""" "bad \q" "open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `a\n\"b\"`},
+		{token.RAW_STRING, "raw \\n \"quoted\"\ntext"},
+		{token.ILLEGAL, `unknown escape sequence \q`},
+		{token.ILLEGAL, "unterminated string"},
+		{token.EOF, ""},
+	}
+
+	lexer := New(input)
+
+	for i, testCase := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testCase.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, testCase.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testCase.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, testCase.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`plain`, "plain"},
+		{`a\nb\tc\r`, "a\nb\tc\r"},
+		{`\"quoted\" \\`, `"quoted" \`},
+		{`\${name}`, "${name}"},
+		{`smile \u{1F600}`, "smile 😀"},
+	}
+
+	for _, testCase := range tests {
+		if got := Unescape(testCase.input); got != testCase.expected {
+			t.Errorf("Unescape(%q) wrong, expected=%q, got=%q", testCase.input, testCase.expected, got)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	input := "1 +\n  /* never closed *\n/"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.INT, 1, 1},
+		{token.PLUS, 1, 3},
+		{token.ILLEGAL, 2, 3},
+		{token.EOF, 3, 2},
+	}
+
+	lexer := New(input)
+
+	for i, testCase := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testCase.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, testCase.expectedType, tok.Type)
+		}
+
+		if tok.Position.Line != testCase.expectedLine || tok.Position.Column != testCase.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong, expected=%d:%d, got=%d:%d", i,
+				testCase.expectedLine, testCase.expectedColumn, tok.Position.Line, tok.Position.Column)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := `jeff's café is "日本";
+名前 + é`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.JEFFS, "jeff's", 1, 1},
+		{token.IDENT, "café", 1, 8},
+		{token.ASSIGN, "is", 1, 13},
+		{token.STRING, "日本", 1, 16},
+		{token.SEMICOLON, ";", 1, 20},
+		{token.IDENT, "名前", 2, 1},
+		{token.PLUS, "+", 2, 4},
+		{token.IDENT, "é", 2, 6},
+		{token.EOF, "", 2, 7},
+	}
+
+	lexer := New(input)
+
+	for i, testCase := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testCase.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, testCase.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testCase.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, testCase.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Position.Line != testCase.expectedLine || tok.Position.Column != testCase.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong, expected=%d:%d, got=%d:%d", i,
+				testCase.expectedLine, testCase.expectedColumn, tok.Position.Line, tok.Position.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `# Adds two numbers
+# and returns the sum
+jeff's add is fn(x, y) { x + y }; # not a doc comment
+/* block
+   comment */ 5 /* inline */ * 2
+
+# separated by a blank line
+
+jeff's z is 1 / 2;
+/* unclosed`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedDoc     string
+	}{
+		{token.JEFFS, "jeff's", "Adds two numbers\nand returns the sum"},
+		{token.IDENT, "add", ""},
+		{token.ASSIGN, "is", ""},
+		{token.FUNCTION, "fn", ""},
+		{token.LPAREN, "(", ""},
+		{token.IDENT, "x", ""},
+		{token.COMMA, ",", ""},
+		{token.IDENT, "y", ""},
+		{token.RPAREN, ")", ""},
+		{token.LBRACE, "{", ""},
+		{token.IDENT, "x", ""},
+		{token.PLUS, "+", ""},
+		{token.IDENT, "y", ""},
+		{token.RBRACE, "}", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.INT, "5", ""},
+		{token.ASTERIX, "*", ""},
+		{token.INT, "2", ""},
+		{token.JEFFS, "jeff's", ""},
+		{token.IDENT, "z", ""},
+		{token.ASSIGN, "is", ""},
+		{token.INT, "1", ""},
+		{token.SLASH, "/", ""},
+		{token.INT, "2", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.ILLEGAL, "unterminated block comment", ""},
+		{token.EOF, "", ""},
+	}
+
+	lexer := New(input)
+
+	for i, testCase := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Type != testCase.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, testCase.expectedType, tok.Type)
+		}
+
+		if tok.Literal != testCase.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", i, testCase.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Doc != testCase.expectedDoc {
+			t.Fatalf("tests[%d] - doc wrong, expected=%q, got=%q", i, testCase.expectedDoc, tok.Doc)
+		}
+	}
+}
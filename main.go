@@ -1,12 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"jeff/ast"
 	"jeff/evaluator"
 	"jeff/lexer"
 	"jeff/object"
 	"jeff/parser"
 	"jeff/repl"
+	"jeff/vm"
 	"os"
 	"os/user"
 	"strings"
@@ -20,10 +23,38 @@ const REPL_HEADER = `
 ///     ||||||||   |||        |||
 `
 
+// Ways of running a program, picked with the -engine flag
+var engines = map[string]struct {
+	// Runs a line of the REPL
+	eval repl.EvalFunc
+	// Runs the file given on the command line
+	evalFile func(path string, program *ast.Program, env *object.Environment) object.Object
+}{
+	"evaluator": {
+		eval: func(program *ast.Program, env *object.Environment) object.Object {
+			return evaluator.Eval(program, env)
+		},
+		evalFile: evaluator.EvalFile,
+	},
+	"vm": {eval: vm.Eval, evalFile: vm.EvalFile},
+}
+
 // Simple Repl
 func main() {
+	checked := flag.Bool("checked", false, "error on integer overflow instead of wrapping around")
+	engine := flag.String("engine", "evaluator", "how to run programs: evaluator (walks the syntax tree) or vm (compiles to bytecode first)")
+	flag.Parse()
+
+	evaluator.CheckedArithmetic = *checked
+	args := flag.Args()
+
+	run, ok := engines[*engine]
+	if !ok {
+		fmt.Printf("ERROR: unknown engine %s, expected evaluator or vm\n", *engine)
+		return
+	}
 
-	if len(os.Args) < 2 {
+	if len(args) < 1 {
 		user, err := user.Current()
 		if err != nil {
 			panic(err)
@@ -33,9 +64,9 @@ func main() {
 		fmt.Printf("Hello %s, Welcome to the Jeff programming language!\n", user.Username)
 		fmt.Println("Type in commands, Type 'exit' to close")
 
-		repl.Start(os.Stdin, os.Stdout)
-	} else if len(os.Args) == 2 {
-		fileName := os.Args[1]
+		repl.Start(os.Stdin, os.Stdout, run.eval)
+	} else if len(args) == 1 {
+		fileName := args[0]
 		if !strings.HasSuffix(fileName, ".jeff") {
 			fmt.Printf("ERROR: file %s is not a .jeff file\n", fileName)
 			return
@@ -49,15 +80,21 @@ func main() {
 			return
 		}
 
-		lexer := lexer.New(string(data))
+		lexer := lexer.NewWithFile(string(data), fileName)
 		parser := parser.New(lexer)
 		program := parser.ParseProgram()
 
 		if len(parser.Errors()) != 0 {
 			repl.PrintParserErrors(os.Stdout, parser.Errors())
+			return
+		}
+
+		evaluated := run.evalFile(fileName, program, env)
+		if err, ok := evaluated.(*object.ERROR); ok {
+			repl.PrintTraceback(os.Stdout, err)
+			return
 		}
 
-		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
 			// io.WriteString(writer, evaluated.Inspect())
 			// io.WriteString(writer, "\n")
@@ -3,7 +3,11 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"jeff/ast"
+	"jeff/code"
+	"jeff/token"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +17,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
 	BOOLEAN_OBJ  = "BOOLEAN"
 	NULL_OBJ     = "NULL"
 	RETURN_OBJ   = "RETURN"
@@ -20,6 +25,18 @@ const (
 	FUNCTION_OBJ = "FUNCTION"
 	STRING_OBJ   = "STRING"
 	BUILTIN_OBJ  = "BUILTIN"
+	ARRAY_OBJ    = "ARRAY"
+	HASH_OBJ     = "HASH"
+	BREAK_OBJ    = "BREAK"
+	CONTINUE_OBJ = "CONTINUE"
+	MODULE_OBJ   = "MODULE"
+	TAIL_OBJ     = "TAIL_CALL"
+
+	STRUCT_TYPE_OBJ = "STRUCT_TYPE"
+	STRUCT_OBJ      = "STRUCT"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
 )
 
 // Objects is the generic interface
@@ -40,6 +57,27 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a decimal point (or exponent) so floats can't be mistaken for integers
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(str, ".eIN") {
+		return str
+	}
+	return str + ".0"
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -56,6 +94,18 @@ func (b *Boolean) Type() ObjectType {
 	return BOOLEAN_OBJ
 }
 
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
 type Null struct{}
 
 func (n *Null) Inspect() string {
@@ -79,11 +129,70 @@ func (r *Return) Type() ObjectType {
 	return RETURN_OBJ
 }
 
+// Break signals the closest loop to stop
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+// Continue signals the closest loop to move to the next iteration
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+// TailCall asks the function being run to make a call for it, so calls in tail
+// position don't add to the Go stack
+type TailCall struct {
+	Function  *Function
+	Arguments []Object
+	Call      *ast.CallExpression
+}
+
+func (tc *TailCall) Inspect() string {
+	return tc.Call.String()
+}
+
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_OBJ
+}
+
+// MaxTailCalls is how many tail calls in a row are kept for a traceback. Older
+// ones are dropped so deep tail recursion runs in constant space
+const MaxTailCalls = 100
+
 type ERROR struct {
 	Message string
+	// Kind of error. Set by raise, empty for errors from the interpreter itself
+	Kind string
+	// Position of the node that caused the error. Set by the evaluator
+	Position token.Position
+	// Function calls the error passed out of. Innermost call first
+	Stack []Frame
+}
+
+// Frame is a single function call in an error's stack
+type Frame struct {
+	// Name the function was bound to with jeff's. Empty for function literals
+	Function string
+	// Where the function was called from
+	Position token.Position
 }
 
 func (e *ERROR) Inspect() string {
+	if e.Position.IsValid() {
+		return "ERROR: " + e.Position.String() + ": " + e.Message
+	}
 	return "ERROR: " + e.Message
 }
 
@@ -91,17 +200,69 @@ func (e *ERROR) Type() ObjectType {
 	return ERROR_OBJ
 }
 
-// Environment stores local variables. Also contains an outer environment
+// Traceback formats the error with its call stack, most recent call last. e.g.
+//
+//	Traceback (most recent call last):
+//	  File "main.jeff", line 4, column 2, in <main>
+//	  File "main.jeff", line 2, column 7, in add
+//	ERROR: type mismatch: INTEGER + BOOLEAN
+func (e *ERROR) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	// Each call happened inside the function of the frame after it.
+	// The outermost call happened at the top level of the program
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		caller := "<main>"
+		if i+1 < len(e.Stack) {
+			caller = frameName(e.Stack[i+1].Function)
+		}
+		out.WriteString(formatFrame(e.Stack[i].Position, caller))
+	}
+
+	// The error itself happened inside the innermost function
+	current := "<main>"
+	if len(e.Stack) > 0 {
+		current = frameName(e.Stack[0].Function)
+	}
+	out.WriteString(formatFrame(e.Position, current))
+
+	out.WriteString("ERROR: " + e.Message)
+
+	return out.String()
+}
+
+func formatFrame(position token.Position, function string) string {
+	file := position.File
+	if file == "" {
+		file = "<repl>"
+	}
+	return fmt.Sprintf("  File \"%s\", line %d, column %d, in %s\n", file, position.Line, position.Column, function)
+}
+
+// frameName gives anonymous functions a name to show in tracebacks
+func frameName(function string) string {
+	if function == "" {
+		return "<fn>"
+	}
+	return function
+}
+
+// Environment stores variables. Also contains an outer environment
 // to check if a desired identifier doesn't exist in the current one.
+// Function calls keep their locals in slots numbered by the resolver, while the top
+// level keeps its variables by name so the REPL and modules can keep adding to it
 type Environment struct {
 	store map[string]Object
+	// Locals of a function call. nil until set
+	slots []Object
 	outer *Environment
 }
 
-func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+// NewFunctionEnvironment creates the environment for a function call, with a slot for each of its locals
+func NewFunctionEnvironment(outer *Environment, locals int) *Environment {
+	return &Environment{slots: make([]Object, locals), outer: outer}
 }
 
 func NewEnvironment() *Environment {
@@ -109,6 +270,8 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// Get looks a variable up by name in this and then the outer environments.
+// Only finds variables kept by name, not the locals of function calls
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -117,15 +280,97 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// GetAt looks up a variable the resolver has found, depth environments out.
+// Returns false for a local that hasn't been set yet
+func (e *Environment) GetAt(depth int, slot int, name string) (Object, bool) {
+	env := e.Outer(depth)
+
+	if slot >= 0 {
+		obj := env.slots[slot]
+		return obj, obj != nil
+	}
+
+	return env.Get(name)
+}
+
+// Set declares a variable by name
 func (e *Environment) Set(name string, val Object) Object {
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
 
+// Delete removes a variable kept by name from this environment
+func (e *Environment) Delete(name string) {
+	delete(e.store, name)
+}
+
+// Define declares a variable in the slot the resolver gave it, or by name at the top level
+func (e *Environment) Define(slot int, name string, val Object) Object {
+	if slot >= 0 {
+		e.slots[slot] = val
+		return val
+	}
+
+	return e.Set(name, val)
+}
+
+// Assign updates an existing variable in whichever environment it was declared in.
+// Returns false if the variable doesn't exist in this or any outer environment
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return nil, false
+}
+
+// AssignAt updates a variable the resolver has found, depth environments out.
+// Returns false if it doesn't exist, or is a local that hasn't been set yet
+func (e *Environment) AssignAt(depth int, slot int, name string, val Object) (Object, bool) {
+	env := e.Outer(depth)
+
+	if slot >= 0 {
+		if env.slots[slot] == nil {
+			return nil, false
+		}
+		env.slots[slot] = val
+		return val, true
+	}
+
+	return env.Assign(name, val)
+}
+
+// Outer returns the environment depth levels out from this one
+func (e *Environment) Outer(depth int) *Environment {
+	env := e
+	for i := 0; i < depth; i++ {
+		env = env.outer
+	}
+	return env
+}
+
 type Function struct {
+	// Name the function was first bound to with jeff's. Empty for anonymous functions
+	Name string
+	// Doc comment written above the jeff's statement that named the function
+	Doc        string
 	Parameters []*ast.Indentifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// Default value of each parameter, nil for required ones. Evaluated on each call
+	Defaults []ast.Expression
+	// Collects extra arguments into an array. nil if the function doesn't take any
+	Rest *ast.Indentifier
+	Body *ast.BlockStatement
+	Env  *Environment
+	// Names of the function's locals, which each call gets a slot for
+	Locals []string
 }
 
 func (f *Function) Type() ObjectType {
@@ -133,24 +378,110 @@ func (f *Function) Type() ObjectType {
 }
 
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Parameters, f.Defaults, f.Rest, f.Body)
+}
+
+// inspectFunction prints a function the way it was written
+func inspectFunction(parameters []*ast.Indentifier, defaults []ast.Expression, rest *ast.Indentifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
 
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range parameters {
+		if i < len(defaults) && defaults[i] != nil {
+			params = append(params, p.String()+" is "+defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+
+	if rest != nil {
+		params = append(params, "..."+rest.String())
 	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// CompiledFunction is a function lowered to bytecode by the compiler, ready to be run by the vm
+type CompiledFunction struct {
+	Instructions code.Instructions
+	// Constant pool the instructions refer to. Shared by everything compiled together
+	Constants []Object
+	// Number of local slots, including the parameters
+	NumLocals     int
+	NumParameters int
+	// Number of parameters without a default
+	NumRequired int
+	// Variadic functions collect extra arguments into an array in the slot after the parameters
+	Variadic bool
+	// Names of the local slots and free variables, for errors
+	LocalNames []string
+	FreeNames  []string
+	// Source position of each instruction, by offset
+	Positions map[int]token.Position
+	// Position of the function being called by each OpCall, for stack traces
+	CallPositions map[int]token.Position
+	// Literal the function was compiled from. nil for the top level of a program
+	Literal *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is a compiled function along with the variables it captured when it was created.
+// It is what a function value is when running on the vm
+type Closure struct {
+	// Name the function was first bound to with jeff's. Empty for anonymous functions
+	Name string
+	// Doc comment written above the jeff's statement that named the function
+	Doc string
+	Fn  *CompiledFunction
+	// Free variables captured from the functions the closure was created in
+	Free []*Cell
+	// Globals of the program or module the closure was created in
+	Globals *Environment
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	if c.Fn.Literal == nil {
+		return "fn() {}"
+	}
+	return inspectFunction(c.Fn.Literal.Parameters, c.Fn.Literal.Defaults, c.Fn.Literal.Rest, c.Fn.Literal.Body)
+}
+
+// Cell holds a local variable that is shared between a function and the closures created inside it.
+// Value is nil until the variable is declared
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "<empty cell>"
+	}
+	return c.Value.Inspect()
+}
+
 type String struct {
 	Value string
 }
@@ -163,6 +494,13 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
 type BuiltInFunction func(args ...Object) Object
 
 type Builtin struct {
@@ -176,3 +514,158 @@ func (b *Builtin) Type() ObjectType {
 func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey is used as the key of a Hash. Two objects with the same type and
+// value will produce the same HashKey even though they are different objects
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by objects that can be used as keys in a Hash
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// HashPair stores the original key object alongside the value
+// so the hash can be printed
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	// Keys in the order they were first added, so hashes print the way they were written
+	Keys []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}}
+}
+
+// Set adds a pair to the hash. A key that is already there keeps its place
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+
+	for _, key := range h.Keys {
+		pair := h.Pairs[key]
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Module is another .jeff file loaded with import.
+// Its top level bindings live in its own environment
+type Module struct {
+	Name string
+	// Absolute path of the file the module was loaded from
+	Path string
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// StructType is a record type declared with struct. Calling it creates an instance
+type StructType struct {
+	// Name the struct was first bound to with jeff's. Empty for anonymous structs
+	Name   string
+	Fields []string
+}
+
+func (st *StructType) Type() ObjectType {
+	return STRUCT_TYPE_OBJ
+}
+
+func (st *StructType) Inspect() string {
+	name := "struct"
+	if st.Name != "" {
+		name += " " + st.Name
+	}
+	return name + " { " + strings.Join(st.Fields, ", ") + " }"
+}
+
+// StructInstance is a value of a struct type. Fields can be changed after it is created
+type StructInstance struct {
+	StructType *StructType
+	Fields     map[string]Object
+	// Set while Inspect is running, so an instance that contains itself is only shown once
+	inspecting bool
+}
+
+func (si *StructInstance) Type() ObjectType {
+	return STRUCT_OBJ
+}
+
+// Inspect shows the fields in the order they were declared. e.g. Point{x: 1, y: 2}
+// An instance inside itself is shown as Point{...}
+func (si *StructInstance) Inspect() string {
+	if si.inspecting {
+		return si.StructType.Name + "{...}"
+	}
+	si.inspecting = true
+	defer func() { si.inspecting = false }()
+
+	var out bytes.Buffer
+
+	fields := []string{}
+
+	for _, name := range si.StructType.Fields {
+		fields = append(fields, fmt.Sprintf("%s: %s", name, si.Fields[name].Inspect()))
+	}
+
+	out.WriteString(si.StructType.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
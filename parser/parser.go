@@ -5,32 +5,51 @@ import (
 	"jeff/ast"
 	"jeff/lexer"
 	"jeff/token"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Order of operator precendences
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	COALESCE
+	OR
+	AND
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
+	POWER // Above prefix so -2 ** 2 is -(2 ** 2)
 	CALL
+	INDEX
 )
 
 // precedences is a mapping of token type to Precedence
 var precedences = map[token.TokenType]int{
-	token.EQUALS:     EQUALS,
-	token.NOT_EQUALS: EQUALS,
-	token.LT:         LESSGREATER,
-	token.GT:         LESSGREATER,
-	token.PLUS:       SUM,
-	token.MINUS:      SUM,
-	token.SLASH:      PRODUCT,
-	token.ASTERIX:    PRODUCT,
-	token.LPAREN:     CALL,
+	token.ASSIGN:       ASSIGN,
+	token.COALESCE:     COALESCE,
+	token.OR:           OR,
+	token.AND:          AND,
+	token.EQUALS:       EQUALS,
+	token.NOT_EQUALS:   EQUALS,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.LT_EQUALS:    LESSGREATER,
+	token.GT_EQUALS:    LESSGREATER,
+	token.PLUS:         SUM,
+	token.MINUS:        SUM,
+	token.SLASH:        PRODUCT,
+	token.ASTERIX:      PRODUCT,
+	token.MODULO:       PRODUCT,
+	token.DOUBLE_SLASH: PRODUCT,
+	token.POWER:        POWER,
+	token.LPAREN:       CALL,
+	token.LBRACKET:     INDEX,
+	token.DOT:          CALL,
 }
 
 type prefixParseFn func() ast.Expression
@@ -63,14 +82,22 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	parser.registerPrefixFn(token.IDENT, parser.parseIdentifier)
 	parser.registerPrefixFn(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefixFn(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefixFn(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefixFn(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefixFn(token.RIGHT, parser.parseBoolean)
 	parser.registerPrefixFn(token.HUANG, parser.parseBoolean)
+	parser.registerPrefixFn(token.NULL, parser.parseNull)
 	parser.registerPrefixFn(token.LPAREN, parser.parseGroupedExpression)
 	parser.registerPrefixFn(token.IF, parser.parseIfStatement)
 	parser.registerPrefixFn(token.FUNCTION, parser.parseFunctionLiteral)
 	parser.registerPrefixFn(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefixFn(token.RAW_STRING, parser.parseRawStringLiteral)
+	parser.registerPrefixFn(token.ILLEGAL, parser.parseIllegal)
+	parser.registerPrefixFn(token.LBRACKET, parser.parseArrayLiteral)
+	parser.registerPrefixFn(token.LBRACE, parser.parseHashLiteral)
+	parser.registerPrefixFn(token.TRY, parser.parseTryExpression)
+	parser.registerPrefixFn(token.STRUCT, parser.parseStructLiteral)
 
 	// Sets infix parsing functions based on the token
 	parser.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -82,11 +109,28 @@ func New(lexer *lexer.Lexer) *Parser {
 	parser.registerInfixFn(token.NOT_EQUALS, parser.parseInfixExpression)
 	parser.registerInfixFn(token.LT, parser.parseInfixExpression)
 	parser.registerInfixFn(token.GT, parser.parseInfixExpression)
+	parser.registerInfixFn(token.LT_EQUALS, parser.parseInfixExpression)
+	parser.registerInfixFn(token.GT_EQUALS, parser.parseInfixExpression)
+	parser.registerInfixFn(token.MODULO, parser.parseInfixExpression)
+	parser.registerInfixFn(token.DOUBLE_SLASH, parser.parseInfixExpression)
+	parser.registerInfixFn(token.POWER, parser.parseInfixExpression)
+	parser.registerInfixFn(token.AND, parser.parseInfixExpression)
+	parser.registerInfixFn(token.OR, parser.parseInfixExpression)
+	parser.registerInfixFn(token.COALESCE, parser.parseInfixExpression)
+	parser.registerInfixFn(token.ASSIGN, parser.parseAssignExpression)
 
 	// This is infix since ( in the token between ident/lit and the arguements list.
 	// i.e add(2,2)
 	parser.registerInfixFn(token.LPAREN, parser.parseCallExpression)
 
+	// Same for [ which sits between the array (or ident) and the index.
+	// i.e. myArray[0]
+	parser.registerInfixFn(token.LBRACKET, parser.parseIndexExpression)
+
+	// And . which sits between a value and a name looked up on it: a module's binding,
+	// a method or a struct field. i.e. utils.add, "jeff".upper, point.x
+	parser.registerInfixFn(token.DOT, parser.parseMemberExpression)
+
 	return parser
 
 }
@@ -95,6 +139,8 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
 }
 
+// Errors returns every error found while parsing.
+// Each error starts with the file:line:col of the token that caused it
 func (p *Parser) Errors() []string {
 	return p.errors
 }
@@ -129,6 +175,14 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseJeffStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -137,7 +191,7 @@ func (p *Parser) parseStatement() ast.Statement {
 // Parse the assign statement in JPL i.e. "jeff's x = 1"
 func (p *Parser) parseJeffStatement() *ast.JeffStatement {
 
-	statement := &ast.JeffStatement{Token: p.currentToken}
+	statement := &ast.JeffStatement{Token: p.currentToken, Doc: p.currentToken.Doc}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -161,6 +215,33 @@ func (p *Parser) parseJeffStatement() *ast.JeffStatement {
 
 }
 
+// Parse an import statement i.e. import "utils.jeff"
+// The module is bound to the file name, so it has to be a valid identifier
+func (p *Parser) parseImportStatement() ast.Statement {
+	statement := &ast.ImportStatement{Token: p.currentToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement.Path = lexer.Unescape(p.currentToken.Literal)
+
+	name := strings.TrimSuffix(filepath.Base(statement.Path), ".jeff")
+	if tok := lexer.New(name).NextToken(); tok.Type != token.IDENT || tok.Literal != name {
+		msg := fmt.Sprintf("%s: can't import %q, module name %q is not a valid identifier", p.currentToken.Position, statement.Path, name)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	nameToken := token.Token{Type: token.IDENT, Literal: name, Position: p.currentToken.Position}
+	statement.Name = &ast.Indentifier{Token: nameToken, Value: name}
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{Token: p.currentToken}
 
@@ -175,6 +256,57 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+// parseWhileStatement parses loops. e.g.
+// while (x < 10) { jeff's x is x + 1 }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	statement := &ast.WhileStatement{Token: p.currentToken}
+
+	// Check for (
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	// Move token and parse the condition
+	p.nextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	statement := &ast.BreakStatement{Token: p.currentToken}
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	statement := &ast.ContinueStatement{Token: p.currentToken}
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 // Expressions as statements are valid in JPL.
 // For instance "2 + 2;" is a valid line.
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
@@ -215,10 +347,181 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
+// parseStringLiteral parses strings. Strings containing ${expression}
+// are parsed as an InterpolatedString instead
 func (p *Parser) parseStringLiteral() ast.Expression {
+	literal := p.currentToken.Literal
+
+	if interpolationStart(literal, 0) == -1 {
+		return &ast.StringLiteral{Token: p.currentToken, Value: lexer.Unescape(literal)}
+	}
+
+	return p.parseInterpolatedString()
+}
+
+// parseRawStringLiteral parses triple quoted strings. These are used exactly as written
+func (p *Parser) parseRawStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
 }
 
+// parseIllegal reports illegal tokens found by the lexer
+func (p *Parser) parseIllegal() ast.Expression {
+	msg := fmt.Sprintf("%s: illegal token: %s", p.currentToken.Position, p.currentToken.Literal)
+	p.errors = append(p.errors, msg)
+	return nil
+}
+
+// parseInterpolatedString splits a string like "hello ${name}!" into its plain string
+// parts and expressions. Each expression is parsed by its own parser
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.currentToken}
+	literal := p.currentToken.Literal
+
+	start := 0
+	for {
+		open := interpolationStart(literal, start)
+		if open == -1 {
+			break
+		}
+
+		if open > start {
+			str.Parts = append(str.Parts, p.stringPart(literal[start:open]))
+		}
+
+		exprStart := open + 2
+		exprEnd := closingBrace(literal, exprStart)
+
+		if exprEnd == -1 {
+			msg := fmt.Sprintf("%s: unterminated ${ in string", p.stringOffsetPosition(open))
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+
+		expression := p.parseEmbeddedExpression(literal[exprStart:exprEnd], p.stringOffsetPosition(exprStart))
+		if expression == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, expression)
+
+		start = exprEnd + 1
+	}
+
+	if start < len(literal) {
+		str.Parts = append(str.Parts, p.stringPart(literal[start:]))
+	}
+
+	return str
+}
+
+// interpolationStart returns the index of the next ${ in the literal from start,
+// skipping escaped characters so \${ isn't counted. Returns -1 if there isn't one
+func interpolationStart(literal string, start int) int {
+	for i := start; i < len(literal); i++ {
+		switch {
+		case literal[i] == '\\':
+			i += 1
+		case literal[i] == '$' && i+1 < len(literal) && literal[i+1] == '{':
+			return i
+		}
+	}
+	return -1
+}
+
+// closingBrace returns the index of the } that closes the ${ whose expression starts at start.
+// Skips over {} pairs and strings inside the expression. Returns -1 if there isn't one
+func closingBrace(literal string, start int) int {
+	depth := 0
+	for i := start; i < len(literal); i++ {
+		switch literal[i] {
+		case '"':
+			i = closingQuote(literal, i)
+			if i == -1 {
+				return -1
+			}
+		case '{':
+			depth += 1
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth -= 1
+		}
+	}
+	return -1
+}
+
+// closingQuote returns the index of the " that closes the string opened at start.
+// Returns -1 if there isn't one
+func closingQuote(literal string, start int) int {
+	for i := start + 1; i < len(literal); i++ {
+		switch {
+		case literal[i] == '\\':
+			i += 1
+		case literal[i] == '"':
+			return i
+		case literal[i] == '$' && i+1 < len(literal) && literal[i+1] == '{':
+			i = closingBrace(literal, i+2)
+			if i == -1 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// stringPart creates a plain string piece of an interpolated string
+func (p *Parser) stringPart(raw string) *ast.StringLiteral {
+	return &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: raw, Position: p.currentToken.Position},
+		Value: lexer.Unescape(raw),
+	}
+}
+
+// parseEmbeddedExpression parses a single expression found inside another token.
+// Errors are added to this parser's errors
+func (p *Parser) parseEmbeddedExpression(input string, position token.Position) ast.Expression {
+	embedded := New(lexer.NewAt(input, position))
+
+	if embedded.currentToken.Type == token.EOF {
+		msg := fmt.Sprintf("%s: empty ${} in string", position)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	expression := embedded.parseExpression(LOWEST)
+
+	if len(embedded.errors) == 0 && embedded.peekToken.Type != token.EOF {
+		msg := fmt.Sprintf("%s: expected } in string, got %s instead!", embedded.peekToken.Position, embedded.peekToken.Type)
+		embedded.errors = append(embedded.errors, msg)
+	}
+
+	if len(embedded.errors) != 0 {
+		p.errors = append(p.errors, embedded.errors...)
+		return nil
+	}
+
+	return expression
+}
+
+// stringOffsetPosition returns the position of a character in the current string token's literal.
+// The literal starts one column after the opening quote
+func (p *Parser) stringOffsetPosition(offset int) token.Position {
+	position := p.currentToken.Position
+	position.Column += 1
+
+	// Columns count characters, not bytes, to match the lexer
+	for _, character := range p.currentToken.Literal[:offset] {
+		if character == '\n' {
+			position.Line += 1
+			position.Column = 1
+		} else {
+			position.Column += 1
+		}
+	}
+
+	return position
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	lit := &ast.IntegerLiteral{Token: p.currentToken}
@@ -226,7 +529,24 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q to integer", p.currentToken.Literal)
+		msg := fmt.Sprintf("%s: Could not parse %q to integer", p.currentToken.Position, p.currentToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+
+	lit := &ast.FloatLiteral{Token: p.currentToken}
+
+	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+
+	if err != nil {
+		msg := fmt.Sprintf("%s: Could not parse %q to float", p.currentToken.Position, p.currentToken.Literal)
 		p.errors = append(p.errors, msg)
 		return nil
 	}
@@ -257,16 +577,62 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.currentPrecendence()
+
+	// Power is right associative. Parsing the right side with a lower precedence
+	// lets another ** be grabbed by the right side. i.e. 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if p.currentToken.Type == token.POWER {
+		precedence -= 1
+	}
+
 	p.nextToken()
 	expresion.Right = p.parseExpression(precedence)
 
 	return expresion
 }
 
+// parseAssignExpression parses reassignment of an existing variable. e.g.
+// x is x + 1
+// Assignment is right associative so "x is y is 1" assigns 1 to both
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	if member, ok := left.(*ast.MemberExpression); ok {
+		return p.parseFieldAssignExpression(member)
+	}
+
+	name, ok := left.(*ast.Indentifier)
+	if !ok {
+		msg := fmt.Sprintf("%s: cannot assign to %s", p.currentToken.Position, left.String())
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	expression := &ast.AssignExpression{Token: p.currentToken, Name: name}
+
+	precedence := p.currentPrecendence()
+	p.nextToken()
+	expression.Value = p.parseExpression(precedence - 1)
+
+	return expression
+}
+
+// parseFieldAssignExpression parses assignment to a struct field i.e. p.x is 3
+func (p *Parser) parseFieldAssignExpression(target *ast.MemberExpression) ast.Expression {
+	expression := &ast.FieldAssignExpression{Token: p.currentToken, Target: target}
+
+	precedence := p.currentPrecendence()
+	p.nextToken()
+	expression.Value = p.parseExpression(precedence - 1)
+
+	return expression
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.currentToken, Value: p.currentToken.Type == token.RIGHT}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.Null{Token: p.currentToken}
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -318,6 +684,45 @@ func (p *Parser) parseIfStatement() ast.Expression {
 
 }
 
+// parseTryExpression parses error handling. e.g.
+// try { riskyThing() } catch (err) { jeffsays(err["message"]) }
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	// Check for the (err) that the error gets bound to
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Param = &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Handler = p.parseBlockStatement()
+
+	return expression
+}
+
 // parseFunctionLiterl parses functions. e.g.
 // fn (x,y) { return x + y }
 func (p *Parser) parseFunctionLiteral() ast.Expression {
@@ -328,7 +733,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	// Check we have the { for the body
 	if !p.expectPeek(token.LBRACE) {
@@ -336,40 +743,141 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Body = p.parseBlockStatement()
+	markTailCalls(lit.Body, true)
+
+	return lit
+}
+
+// markTailCalls marks the calls in a function body whose result is returned straight away, so
+// they can be made without growing the stack. That is every return and, when tail is set, the
+// last expression of the block. Returns inside a try block are left alone as the try still
+// has to catch their errors
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	for i, statement := range block.Statements {
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(statement.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			markTailExpression(statement.Expression, tail && i == len(block.Statements)-1)
+		case *ast.WhileStatement:
+			markTailCalls(statement.Body, false)
+		}
+	}
+}
+
+// markTailExpression marks the expression if it's a call in tail position, and looks for
+// returns in any blocks it has
+func markTailExpression(expression ast.Expression, tail bool) {
+	switch expression := expression.(type) {
+	case *ast.CallExpression:
+		if expression != nil && tail {
+			expression.Tail = true
+		}
+	case *ast.IfExpression:
+		if expression == nil {
+			return
+		}
+		markTailCalls(expression.Consequence, tail)
+		if expression.Alternative != nil {
+			markTailCalls(expression.Alternative, tail)
+		}
+	case *ast.TryExpression:
+		if expression != nil {
+			markTailCalls(expression.Handler, tail)
+		}
+	}
+}
+
+// parseStructLiteral parses a struct declaration i.e. struct { x, y }
+func (p *Parser) parseStructLiteral() ast.Expression {
+	lit := &ast.StructLiteral{Token: p.currentToken, Fields: []*ast.Indentifier{}}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for p.peekToken.Type != token.RBRACE {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		field := &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if seen[field.Value] {
+			msg := fmt.Sprintf("%s: duplicate struct field %s", p.currentToken.Position, field.Value)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		seen[field.Value] = true
+		lit.Fields = append(lit.Fields, field)
+
+		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Indentifier {
-	identifiers := []*ast.Indentifier{}
+// parseFunctionParameters parses the parameter list of a function into the literal. e.g.
+// (x, y is 10, ...rest)
+// Once a parameter has a default every parameter after it needs one, and ...rest has to be last
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Indentifier{}
+	lit.Defaults = []ast.Expression{}
 
 	// Handle empty parameter list
 	if p.peekToken.Type == token.RPAREN {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	for {
+		if p.peekToken.Type == token.ELLIPSIS {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+			if p.peekToken.Type == token.COMMA {
+				msg := fmt.Sprintf("%s: rest parameter ...%s must be the last parameter", lit.Rest.Position(), lit.Rest.Value)
+				p.errors = append(p.errors, msg)
+				return false
+			}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
-	// Grab first param
-	ident := &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		var defaultValue ast.Expression
+		if p.peekToken.Type == token.ASSIGN {
+			p.nextToken()
+			p.nextToken()
+			// Parsed above assignment so the default can't swallow a following "is"
+			defaultValue = p.parseExpression(ASSIGN)
+		} else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+			msg := fmt.Sprintf("%s: parameter %s without a default follows a parameter with one", ident.Position(), ident.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
 
-	identifiers = append(identifiers, ident)
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.Defaults = append(lit.Defaults, defaultValue)
 
-	// while there are more params, keep grabbing them
-	for p.peekToken.Type == token.COMMA {
-		p.nextToken() // now current token is comma
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
 		p.nextToken()
-		ident := &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
 	// Check that we have a closed paren
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
-
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
 
 // parseCallExpression parsed function calls
@@ -379,35 +887,142 @@ func (p *Parser) parseFunctionParameters() []*ast.Indentifier {
 // and then the call statement (2,2)
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currentToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
+// parseArrayLiteral parses a list of expressions wrapped in []
+// e.g. [1, 2 + 2, add(1, 2)]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.currentToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
 
-	args := []ast.Expression{}
+// parseMemberExpression parses a name looked up on a value with .
+// e.g. utils.add for a module binding, "jeff".upper for a method or point.x for a struct field
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.currentToken, Left: left}
 
-	// Check for empty arg list.
-	if p.peekToken.Type == token.RPAREN {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Indentifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	return exp
+}
+
+// parseIndexExpression parses the index access on an expression
+// e.g. myArray[1 + 1]
+// If a : is found it's parsed as a slice instead. e.g. myArray[1:3]
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	bracket := p.currentToken
+
+	// Slice with no start. i.e. myArray[:2]
+	if p.peekToken.Type == token.COLON {
 		p.nextToken()
-		return args
+		return p.parseSliceExpression(bracket, left, nil)
 	}
 
+	exp := &ast.IndexExpression{Token: bracket, Left: left}
+
 	p.nextToken()
-	// Parse first arg, which is an expression
-	args = append(args, p.parseExpression(LOWEST))
+	exp.Index = p.parseExpression(LOWEST)
+
+	if p.peekToken.Type == token.COLON {
+		p.nextToken()
+		return p.parseSliceExpression(bracket, left, exp.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseSliceExpression parses the rest of a slice once the : has been found
+func (p *Parser) parseSliceExpression(bracket token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: bracket, Left: left, Start: start}
+
+	// Slice with no end. i.e. myArray[1:]
+	if p.peekToken.Type == token.RBRACKET {
+		p.nextToken()
+		return exp
+	}
+
+	p.nextToken()
+	exp.End = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseHashLiteral parses key value pairs wrapped in {}
+// e.g. {"one": 1, "two": 1 + 1}
+// Block statements also use {} but they are only ever parsed directly after
+// an if, else or fn. So a { found at the start of an expression is always a hash
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.currentToken, Pairs: []ast.HashPair{}}
+
+	// While not at the closing brace, grab key: value pairs
+	for p.peekToken.Type != token.RBRACE {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		// Pairs must be separated by commas unless it's the last one
+		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
+
+// parseExpressionList parses comma separated expressions until the end token is found.
+// Used for both call arguments (end token ")") and array elements (end token "]")
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+
+	list := []ast.Expression{}
+
+	// Check for empty list.
+	if p.peekToken.Type == end {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	// Parse first element, which is an expression
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
 
 }
 
@@ -448,12 +1063,12 @@ func (p *Parser) expectPeek(tokenType token.TokenType) bool {
 
 // peekError writes error message to errors regarding tokType not matching the peekToken type
 func (p *Parser) peekError(tokType token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead!", tokType, p.peekToken.Type)
+	msg := fmt.Sprintf("%s: expected next token to be %s, got %s instead!", p.peekToken.Position, tokType, p.peekToken.Type)
 	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("No prefix parse function found for token %s", t)
+	msg := fmt.Sprintf("%s: No prefix parse function found for token %s", p.currentToken.Position, t)
 	p.errors = append(p.errors, msg)
 }
 
@@ -145,6 +145,33 @@ func TestIntegerLiteralExpressions(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpressions(t *testing.T) {
+	input := "2.5"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statement %T could not be converted to an ExpressionStatement", program.Statements[0])
+	}
+
+	literal, ok := statement.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("Expression %T could not be converted to FloatLiteral", statement.Expression)
+	}
+
+	if literal.Value != 2.5 {
+		t.Errorf("Expected FloatLiteral value to be 2.5, but was %f", literal.Value)
+	}
+
+	if literal.TokenLiteral() != "2.5" {
+		t.Errorf("Expected FloatLiteral token literal to be 2.5 but was %s", literal.TokenLiteral())
+	}
+}
+
 func TestBoolean(t *testing.T) {
 	inputTests := []struct {
 		input    string
@@ -249,6 +276,11 @@ func TestParseInfixExpressions(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"5 // 5", 5, "//", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
 	}
 
 	for _, testCase := range infixTests {
@@ -319,6 +351,62 @@ func TestOperatorPrecedence(t *testing.T) {
 			"1 * (2 + 3)",
 			"(1 * (2 + 3))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a % b + c // d",
+			"((a % b) + (c // d))",
+		},
+		{
+			"a + b <= c >= d",
+			"(((a + b) <= c) >= d)",
+		},
+		{
+			"a or b and c",
+			"(a or (b and c))",
+		},
+		{
+			"a == b and c < d or !e",
+			"(((a == b) and (c < d)) or (!e))",
+		},
+		{
+			"x is a or b",
+			"(x is (a or b))",
+		},
+		{
+			"x is y is a + b",
+			"(x is (y is (a + b)))",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])),(b[1]),(2 * ([1, 2][1])))",
+		},
+		{
+			"a ?? b or c ?? d",
+			"((a ?? (b or c)) ?? d)",
+		},
+		{
+			"x is a ?? null",
+			"(x is (a ?? null))",
+		},
+		{
+			"-a.b() * c.d[0]",
+			"((-a.b()) * (c.d[0]))",
+		},
 	}
 
 	for _, testCase := range tests {
@@ -445,6 +533,264 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { break; continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[0] is not ast.BreakStatement. got=%T", stmt.Body.Statements[0])
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	input := `x is x + 1;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Name, "x") {
+		return
+	}
+
+	testInfixExpression(t, exp.Value, "x", "+", 1)
+}
+
+func TestAssignExpressionInvalidTarget(t *testing.T) {
+	l := lexer.New("5 is 6;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Expected 1 parser error, got %d", len(p.Errors()))
+	}
+
+	if p.Errors()[0] != "1:3: cannot assign to 5" {
+		t.Errorf("Unexpected error. got=%q", p.Errors()[0])
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedPath string
+		expectedName string
+	}{
+		{`import "utils.jeff";`, "utils.jeff", "utils"},
+		{`import "lib/strings"`, "lib/strings", "strings"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Path != tt.expectedPath {
+			t.Errorf("stmt.Path wrong. expected=%q, got=%q", tt.expectedPath, stmt.Path)
+		}
+
+		testIdentifier(t, stmt.Name, tt.expectedName)
+	}
+}
+
+func TestImportStatementInvalidName(t *testing.T) {
+	l := lexer.New(`import "my-utils.jeff"`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Expected 1 parser error, got %d", len(p.Errors()))
+	}
+
+	expected := `1:8: can't import "my-utils.jeff", module name "my-utils" is not a valid identifier`
+	if p.Errors()[0] != expected {
+		t.Errorf("Unexpected error. got=%q", p.Errors()[0])
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	l := lexer.New("utils.add(1, 2)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	member, ok := call.Function.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("call.Function is not ast.MemberExpression. got=%T", call.Function)
+	}
+
+	testIdentifier(t, member.Left, "utils")
+	testIdentifier(t, member.Property, "add")
+
+	if stmt.String() != "utils.add(1,2)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestStructLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedFields []string
+	}{
+		{"struct { x, y }", []string{"x", "y"}},
+		{"struct { x, }", []string{"x"}},
+		{"struct {}", []string{}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		lit, ok := stmt.Expression.(*ast.StructLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.StructLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(lit.Fields) != len(tt.expectedFields) {
+			t.Fatalf("wrong number of fields. expected=%d, got=%d", len(tt.expectedFields), len(lit.Fields))
+		}
+
+		for i, field := range tt.expectedFields {
+			testIdentifier(t, lit.Fields[i], field)
+		}
+	}
+}
+
+func TestStructLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { x, x }", "1:13: duplicate struct field x"},
+		{"struct { x y }", "1:12: expected next token to be ,, got IDENT instead!"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("Unexpected errors. expected first=%q, got=%q", tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestFieldAssignExpression(t *testing.T) {
+	l := lexer.New("p.x is p.x + 1;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.FieldAssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FieldAssignExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Target.String() != "p.x" {
+		t.Errorf("exp.Target wrong. got=%q", exp.Target.String())
+	}
+
+	if exp.Value.String() != "(p.x + 1)" {
+		t.Errorf("exp.Value wrong. got=%q", exp.Value.String())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	input := `try { x } catch (err) { y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Block.Statements) != 1 {
+		t.Fatalf("block is not 1 statements. got=%d\n", len(exp.Block.Statements))
+	}
+
+	block := exp.Block.Statements[0].(*ast.ExpressionStatement)
+	if !testIdentifier(t, block.Expression, "x") {
+		return
+	}
+
+	if !testIdentifier(t, exp.Param, "err") {
+		return
+	}
+
+	if len(exp.Handler.Statements) != 1 {
+		t.Fatalf("handler is not 1 statements. got=%d\n", len(exp.Handler.Statements))
+	}
+
+	handler := exp.Handler.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, handler.Expression, "y")
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -522,6 +868,54 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, y is 10) {}", "fn(x,y is 10)"},
+		{"fn(x is 1 + 2, y is x) {}", "fn(x is (1 + 2),y is x)"},
+		{"fn(first, ...rest) {}", "fn(first,...rest)"},
+		{"fn(...rest) {}", "fn(...rest)"},
+		{"fn(x, y is 2, ...rest) {}", "fn(x,y is 2,...rest)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if actual := function.String(); actual != tt.expected {
+			t.Errorf("function.String() wrong. expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x is 1, y) {}", "1:12: parameter y without a default follows a parameter with one"},
+		{"fn(...rest, x) {}", "1:7: rest parameter ...rest must be the last parameter"},
+		{"fn(1) {}", "1:4: expected next token to be IDENT, got INT instead!"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("Unexpected errors. expected first=%q, got=%q", tt.expected, p.Errors())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -560,6 +954,84 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"fn() { f() }", true},
+		{"fn() { return f() }", true},
+		{"fn() { f(); 1 }", false},
+		{"fn() { 1 + f() }", false},
+		{"fn() { if (x) { f() } else { 1 } }", true},
+		{"fn() { if (x) { f() }; 1 }", false},
+		{"fn() { if (x) { return f() }; 1 }", true},
+		{"fn() { while (x) { f() } }", false},
+		{"fn() { while (x) { return f() } }", true},
+		{"fn() { try { f() } catch (e) { 1 } }", false},
+		{"fn() { try { return f() } catch (e) { 1 } }", false},
+		{"fn() { try { 1 } catch (e) { f() } }", true},
+		{"f()", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		call := findCall(program)
+		if call == nil {
+			t.Fatalf("no call found in %q", tt.input)
+		}
+
+		if call.Tail != tt.expected {
+			t.Errorf("wrong tail marking for %q. expected=%t, got=%t", tt.input, tt.expected, call.Tail)
+		}
+	}
+}
+
+// findCall returns the first call to f in the node
+func findCall(node ast.Node) *ast.CallExpression {
+	var children []ast.Node
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, statement := range node.Statements {
+			children = append(children, statement)
+		}
+	case *ast.BlockStatement:
+		for _, statement := range node.Statements {
+			children = append(children, statement)
+		}
+	case *ast.ExpressionStatement:
+		children = append(children, node.Expression)
+	case *ast.ReturnStatement:
+		children = append(children, node.ReturnValue)
+	case *ast.WhileStatement:
+		children = append(children, node.Body)
+	case *ast.FunctionLiteral:
+		children = append(children, node.Body)
+	case *ast.InfixExpression:
+		children = append(children, node.Left, node.Right)
+	case *ast.IfExpression:
+		children = append(children, node.Consequence)
+	case *ast.TryExpression:
+		children = append(children, node.Block, node.Handler)
+	case *ast.CallExpression:
+		if node.Function.String() == "f" {
+			return node
+		}
+	}
+
+	for _, child := range children {
+		if call := findCall(child); call != nil {
+			return call
+		}
+	}
+	return nil
+}
+
 func TestCallExpressionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -633,6 +1105,330 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringEscapeParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"tab\there"`, "tab\there"},
+		{`"line\n\"quoted\""`, "line\n\"quoted\""},
+		{`"\${not interpolated}"`, "${not interpolated}"},
+		{`"\u{1F600}"`, "\U0001F600"},
+		{`"""raw \n ${x}"""`, `raw \n ${x}`},
+	}
+
+	for _, testCase := range tests {
+		l := lexer.New(testCase.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != testCase.expected {
+			t.Errorf("literal.Value not %q. got=%q", testCase.expected, literal.Value)
+		}
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"open`, "1:1: illegal token: unterminated string"},
+		{`jeff's x is "bad \q";`, "1:13: illegal token: unknown escape sequence \\q"},
+		{`"\u{110000}"`, "1:1: illegal token: invalid unicode code point \\u{110000}"},
+		{`"""never closed`, "1:1: illegal token: unterminated raw string"},
+	}
+
+	for _, testCase := range tests {
+		l := lexer.New(testCase.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %s but got none", testCase.input)
+			continue
+		}
+
+		if p.Errors()[0] != testCase.expected {
+			t.Errorf("Unexpected error. Expected %q but got %q", testCase.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiteralsStringKeys(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	if hash.String() != `{one:1, two:2, three:3}` {
+		t.Errorf("pairs are out of order. got=%s", hash.String())
+	}
+
+	expected := map[string]int64{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+		}
+
+		expectedValue := expected[literal.String()]
+
+		testIntegerLiteral(t, pair.Value, expectedValue)
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	tests := map[string]func(ast.Expression){
+		"one": func(e ast.Expression) {
+			testInfixExpression(t, e, 0, "+", 1)
+		},
+		"two": func(e ast.Expression) {
+			testInfixExpression(t, e, 10, "-", 8)
+		},
+		"three": func(e ast.Expression) {
+			testInfixExpression(t, e, 15, "/", 5)
+		},
+	}
+
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+
+		testFunc, ok := tests[literal.String()]
+		if !ok {
+			t.Errorf("No test function for key %q found", literal.String())
+			continue
+		}
+
+		testFunc(pair.Value)
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	input := `jeff's x is 5;
+jeff's y 10;`
+
+	l := lexer.NewWithFile(input, "test.jeff")
+	p := New(l)
+	p.ParseProgram()
+
+	expected := "test.jeff:2:10: expected next token to be is, got INT instead!"
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("Expected parser errors but got none")
+	}
+
+	if p.Errors()[0] != expected {
+		t.Errorf("Unexpected error. Expected %q but got %q", expected, p.Errors()[0])
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[1 + 1:len(a) - 1]", "(a[(1 + 1):(len(a) - 1)])"},
+	}
+
+	for _, testCase := range tests {
+		l := lexer.New(testCase.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+
+		if program.String() != testCase.expected {
+			t.Errorf("Expected %s but got %s", testCase.expected, program.String())
+		}
+	}
+}
+
+func TestParsingInterpolatedStrings(t *testing.T) {
+	input := `"hello ${name}, you are ${age + 1}!"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. want=5, got=%d", len(str.Parts))
+	}
+
+	for i, expected := range []string{"hello ", ", you are ", "!"} {
+		part, ok := str.Parts[i*2].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("part %d is not *ast.StringLiteral. got=%T", i*2, str.Parts[i*2])
+		}
+		if part.Value != expected {
+			t.Errorf("part %d wrong. want=%q, got=%q", i*2, expected, part.Value)
+		}
+	}
+
+	testIdentifier(t, str.Parts[1], "name")
+	testInfixExpression(t, str.Parts[3], "age", "+", 1)
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hi ${name"`, "1:1: illegal token: unterminated ${ in string"},
+		{`"hi ${}"`, "1:7: empty ${} in string"},
+		{`"hi ${a b}"`, "1:9: expected } in string, got IDENT instead!"},
+		{"\"one\ntwo ${)}\"", "2:7: No prefix parse function found for token )"},
+	}
+
+	for _, testCase := range tests {
+		l := lexer.New(testCase.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("Expected parser errors for %s but got none", testCase.input)
+			continue
+		}
+
+		if p.Errors()[0] != testCase.expected {
+			t.Errorf("Unexpected error. Expected %q but got %q", testCase.expected, p.Errors()[0])
+		}
+	}
+}
+
 func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	intLiteral, ok := il.(*ast.IntegerLiteral)
 
@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"jeff/evaluator"
+	"jeff/ast"
 	"jeff/lexer"
 	"jeff/object"
 	"jeff/parser"
@@ -12,8 +12,11 @@ import (
 
 const PROMPT = ">>"
 
-// Start the REPL. Keeps state so inputs can reuse variables
-func Start(reader io.Reader, writer io.Writer) {
+// EvalFunc runs a program in an environment, like evaluator.Eval or vm.Eval
+type EvalFunc func(program *ast.Program, env *object.Environment) object.Object
+
+// Start the REPL, running each line with eval. Keeps state so inputs can reuse variables
+func Start(reader io.Reader, writer io.Writer, eval EvalFunc) {
 	scanner := bufio.NewScanner(reader)
 	env := object.NewEnvironment()
 
@@ -37,7 +40,12 @@ func Start(reader io.Reader, writer io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluated := eval(program, env)
+		if err, ok := evaluated.(*object.ERROR); ok {
+			PrintTraceback(writer, err)
+			continue
+		}
+
 		if evaluated != nil {
 			io.WriteString(writer, evaluated.Inspect())
 			io.WriteString(writer, "\n")
@@ -50,3 +58,8 @@ func PrintParserErrors(out io.Writer, errors []string) {
 		io.WriteString(out, msg+"\n")
 	}
 }
+
+// PrintTraceback writes an error along with the function calls it came out of
+func PrintTraceback(out io.Writer, err *object.ERROR) {
+	io.WriteString(out, err.Traceback()+"\n")
+}
@@ -0,0 +1,199 @@
+package resolver
+
+import (
+	"jeff/ast"
+	"jeff/object"
+)
+
+// scope is a function whose variables are being resolved, or the top level when fn is nil
+type scope struct {
+	outer *scope
+	fn    *ast.FunctionLiteral
+	// Slot of each of the function's locals
+	slots map[string]int
+	// Locals declared so far in the function's own code
+	declared map[string]bool
+	// Catch parameters of the handlers being resolved, innermost last
+	catches []*ast.Indentifier
+	// Names declared at the top level
+	globals map[string]bool
+}
+
+// resolver fills in where each identifier in a program lives
+type resolver struct {
+	scope *scope
+	// Reports whether a global the program doesn't declare already exists
+	defined func(name string) bool
+	// Unresolved identifier that comes first in the source
+	err *object.ERROR
+}
+
+// Resolve works out where each variable in the program lives so the evaluator doesn't have to
+// search for them by name. Each identifier gets the number of functions out it was declared and
+// its slot there, and each function literal gets the names of its locals.
+// A name belongs to the innermost function that declares it with jeff's, a parameter or import. The function itself can only use it after the declaration, before that the name means
+// whatever it does outside. Functions inside it can use it anywhere, as they usually run later.
+// A catch parameter only exists inside its handler, hiding anything else with the name. In a
+// function it gets a slot of its own. Names no function declares are globals.
+// defined reports whether a global the program doesn't declare itself exists anyway, e.g. a
+// builtin or one from an earlier line in the REPL.
+// Returns an error for the first identifier that isn't declared anywhere
+func Resolve(program *ast.Program, defined func(name string) bool) *object.ERROR {
+	r := &resolver{defined: defined}
+
+	r.scope = &scope{globals: map[string]bool{}}
+	declare(program, func(name *ast.Indentifier) {
+		r.scope.globals[name.Value] = true
+		name.Depth, name.Slot = 0, -1
+	})
+
+	r.resolve(program)
+
+	return r.err
+}
+
+// resolve resolves the identifiers in the node in the order they run, so each declaration
+// is only seen by the code after it. Functions inside it get their own scope
+func (r *resolver) resolve(node ast.Node) {
+	ast.Walk(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			r.resolveFunction(node)
+			return false
+		case *ast.JeffStatement:
+			r.resolve(node.Value)
+			r.markDeclared(node.Name)
+			return false
+		case *ast.ImportStatement:
+			r.markDeclared(node.Name)
+		case *ast.TryExpression:
+			r.resolve(node.Block)
+			r.resolveHandler(node)
+			return false
+		case *ast.Indentifier:
+			r.resolveIdentifier(node)
+		}
+		return true
+	})
+}
+
+// resolveFunction gives the function's parameters the first slots, followed by the rest
+// parameter and then everything declared in its body.
+// Each parameter's default can only use the parameters before it
+func (r *resolver) resolveFunction(fn *ast.FunctionLiteral) {
+	r.scope = &scope{outer: r.scope, fn: fn, slots: map[string]int{}, declared: map[string]bool{}}
+	fn.Locals = []string{}
+
+	local := func(name *ast.Indentifier) {
+		slot, ok := r.scope.slots[name.Value]
+		if !ok {
+			slot = len(fn.Locals)
+			r.scope.slots[name.Value] = slot
+			fn.Locals = append(fn.Locals, name.Value)
+		}
+		name.Depth, name.Slot = 0, slot
+	}
+
+	for _, param := range fn.Parameters {
+		local(param)
+	}
+	if fn.Rest != nil {
+		local(fn.Rest)
+	}
+	declare(fn.Body, local)
+
+	for i, param := range fn.Parameters {
+		if i < len(fn.Defaults) {
+			r.resolve(fn.Defaults[i])
+		}
+		r.markDeclared(param)
+	}
+	if fn.Rest != nil {
+		r.markDeclared(fn.Rest)
+	}
+	r.resolve(fn.Body)
+
+	r.scope = r.scope.outer
+}
+
+// resolveHandler resolves the catch block of a try with its parameter in scope
+func (r *resolver) resolveHandler(node *ast.TryExpression) {
+	param := node.Param
+	param.Depth, param.Slot = 0, -1
+	if fn := r.scope.fn; fn != nil {
+		param.Slot = len(fn.Locals)
+		fn.Locals = append(fn.Locals, param.Value)
+	}
+
+	r.scope.catches = append(r.scope.catches, param)
+	r.resolve(node.Handler)
+	r.scope.catches = r.scope.catches[:len(r.scope.catches)-1]
+}
+
+// markDeclared marks a local as usable by the rest of the function's own code
+func (r *resolver) markDeclared(name *ast.Indentifier) {
+	if r.scope.fn != nil {
+		r.scope.declared[name.Value] = true
+	}
+}
+
+// resolveIdentifier finds the innermost function declaring the name, falling back to the top level
+func (r *resolver) resolveIdentifier(name *ast.Indentifier) {
+	depth := 0
+	s := r.scope
+
+	for ; s.fn != nil; s = s.outer {
+		if param := s.catch(name.Value); param != nil {
+			name.Depth, name.Slot = depth, param.Slot
+			return
+		}
+		if slot, ok := s.slots[name.Value]; ok && (depth > 0 || s.declared[name.Value]) {
+			name.Depth, name.Slot = depth, slot
+			return
+		}
+		depth += 1
+	}
+
+	name.Depth, name.Slot = depth, -1
+
+	if !s.globals[name.Value] && s.catch(name.Value) == nil && !r.defined(name.Value) {
+		r.unresolved(name)
+	}
+}
+
+// catch finds the innermost catch parameter in scope with the name
+func (s *scope) catch(name string) *ast.Indentifier {
+	for i := len(s.catches) - 1; i >= 0; i-- {
+		if s.catches[i].Value == name {
+			return s.catches[i]
+		}
+	}
+	return nil
+}
+
+// unresolved records an identifier that can't be found, keeping whichever comes first
+func (r *resolver) unresolved(name *ast.Indentifier) {
+	position := name.Position()
+	if r.err != nil && (r.err.Position.Line < position.Line ||
+		r.err.Position.Line == position.Line && r.err.Position.Column < position.Column) {
+		return
+	}
+
+	r.err = &object.ERROR{Message: "identifier not found: " + name.Value, Position: position}
+}
+
+// declare calls fn for each name declared in the node with jeff's or import.
+// Functions inside it are skipped as they have their own locals
+func declare(node ast.Node, fn func(name *ast.Indentifier)) {
+	ast.Walk(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.JeffStatement:
+			fn(node.Name)
+		case *ast.ImportStatement:
+			fn(node.Name)
+		}
+		return true
+	})
+}
@@ -0,0 +1,107 @@
+package resolver
+
+import (
+	"fmt"
+	"jeff/ast"
+	"jeff/lexer"
+	"jeff/parser"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"jeff's x is 1; x", []string{"x 0:-1"}},
+		{"len", []string{"len 0:-1"}},
+		{"fn(a, b) { a + b }", []string{"a 0:0", "b 0:1"}},
+		{"fn(a, ...rest) { jeff's c is rest; c }", []string{"rest 0:1", "c 0:2"}},
+		{"fn(a, b is a) { b }", []string{"a 0:0", "b 0:1"}},
+		{"jeff's x is 1; fn() { x }", []string{"x 1:-1"}},
+		{"fn(a) { fn(b) { fn() { a + b } } }", []string{"a 2:0", "b 1:0"}},
+		{"fn() { jeff's a is 1; a is 2; jeff's a is 3 }", []string{"a 0:0"}},
+		{"fn() { if (right) { jeff's a is 1 }; while (a) { jeff's b is 2 }; b }", []string{"a 0:0", "b 0:1"}},
+		{"fn() { try { 1 } catch (e) { e } }", []string{"e 0:0"}},
+		{"fn() { jeff's e is 1; try { 1 } catch (e) { fn() { e } }; e }", []string{"e 1:1", "e 0:0"}},
+		{"try { 1 } catch (e) { fn() { e } }", []string{"e 1:-1"}},
+		{"fn() { import \"utils\"; utils.add }", []string{"utils 0:0"}},
+		{"fn() { x }; jeff's x is 1", []string{"x 1:-1"}},
+		{"fn(a) { fn() { jeff's a is 1; a } }", []string{"a 0:0"}},
+		{"jeff's x is 1; fn() { jeff's y is x; jeff's x is 2; x }", []string{"x 1:-1", "x 0:1"}},
+		{"fn() { fn() { x }; jeff's x is 1 }", []string{"x 1:0"}},
+		{"jeff's e is 1; fn() { e; try { 1 } catch (e) { e } }", []string{"e 1:-1", "e 0:0"}},
+		{"jeff's b is 1; fn(a is b, b is 2) { b }", []string{"b 1:-1", "b 0:1"}},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		if err := Resolve(program, func(name string) bool { return name == "len" }); err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err.Inspect())
+			continue
+		}
+
+		got := []string{}
+		ast.Walk(program, func(node ast.Node) bool {
+			if identifier, ok := node.(*ast.Indentifier); ok {
+				got = append(got, fmt.Sprintf("%s %d:%d", identifier.Value, identifier.Depth, identifier.Slot))
+			}
+			return true
+		})
+
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("wrong resolution for %q. expected=%v, got=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionLocals(t *testing.T) {
+	program := parse(t, "fn(a, b is 1, ...rest) { jeff's c is a; try { c } catch (e) { jeff's a is e }; fn(d) { jeff's f is d } }")
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if err := Resolve(program, func(string) bool { return false }); err != nil {
+		t.Fatalf("unexpected error: %s", err.Inspect())
+	}
+
+	expected := []string{"a", "b", "rest", "c", "e"}
+	if fmt.Sprint(fn.Locals) != fmt.Sprint(expected) {
+		t.Errorf("wrong locals. expected=%v, got=%v", expected, fn.Locals)
+	}
+}
+
+func TestUnresolvedIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foobar", "1:1: identifier not found: foobar"},
+		{"foobar is 1", "1:1: identifier not found: foobar"},
+		{"if (huang) { foobar }", "1:14: identifier not found: foobar"},
+		{"fn() {\n  a + b\n}", "2:3: identifier not found: a"},
+		{"fn(x) { x }; x", "1:14: identifier not found: x"},
+		{`{"a": b, "c": d}`, "1:7: identifier not found: b"},
+		{"try { 1 } catch (e) { e }; e", "1:28: identifier not found: e"},
+	}
+
+	for _, tt := range tests {
+		err := Resolve(parse(t, tt.input), func(string) bool { return false })
+		if err == nil {
+			t.Errorf("expected an error for %q", tt.input)
+			continue
+		}
+
+		if got := err.Position.String() + ": " + err.Message; got != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
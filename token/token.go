@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 // token's used in lexer
 const (
 	ILLEGAL = "ILLEGAL"
@@ -7,6 +9,7 @@ const (
 
 	IDENT = "IDENT"
 	INT   = "INT"
+	FLOAT = "FLOAT"
 
 	ASSIGN     = "is"
 	PLUS       = "+"
@@ -19,13 +22,30 @@ const (
 	EQUALS     = "=="
 	NOT_EQUALS = "!="
 
+	MODULO       = "%"
+	POWER        = "**"
+	DOUBLE_SLASH = "//"
+	LT_EQUALS    = "<="
+	GT_EQUALS    = ">="
+
+	AND = "AND"
+	OR  = "OR"
+
+	// a ?? b is a unless a is null
+	COALESCE = "??"
+
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
+	ELLIPSIS  = "..."
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	FUNCTION = "FUNCTION"
 	JEFFS    = "JEFFS"
@@ -35,25 +55,70 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	STRING   = "STRING"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	IMPORT   = "IMPORT"
+	STRUCT   = "STRUCT"
+	NULL     = "NULL"
+
+	// Triple quoted strings with no escape sequences
+	RAW_STRING = "RAW_STRING"
 )
 
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type     TokenType
+	Literal  string
+	Position Position
+	// Doc is the text of any # comment lines directly above the token
+	Doc string
+}
+
+// Position is where a token starts in the source. Lines and columns start at 1.
+// File is empty when the source didn't come from a file (i.e. the REPL)
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position has been set
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as file:line:col, or line:col if there is no file
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 // special words in JPL that are not variable names
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"jeff's": JEFFS,
-	"right":  RIGHT,
-	"huang":  HUANG,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"is":     ASSIGN,
+	"fn":       FUNCTION,
+	"jeff's":   JEFFS,
+	"right":    RIGHT,
+	"huang":    HUANG,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"is":       ASSIGN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"and":      AND,
+	"or":       OR,
+	"import":   IMPORT,
+	"struct":   STRUCT,
+	"null":     NULL,
 }
 
 func LookupIdentifier(identifier string) TokenType {
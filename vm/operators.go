@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"jeff/code"
+	"jeff/evaluator"
+	"jeff/object"
+)
+
+// operandWidth is the size of each opcode's operands, so the vm can step over them
+var operandWidth [256]int
+
+func init() {
+	for op := 0; op < 256; op++ {
+		def, err := code.Lookup(byte(op))
+		if err != nil {
+			continue
+		}
+		for _, width := range def.OperandWidths {
+			operandWidth[op] += width
+		}
+	}
+}
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpFloorDiv:     "//",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+}
+
+// infix applies the operator of an infix opcode. Integer comparisons and unchecked
+// addition and subtraction are handled here as they are the most common, everything
+// else goes through the evaluator so the result is the same
+func infix(op code.Opcode, left, right object.Object) object.Object {
+	if leftInt, ok := left.(*object.Integer); ok {
+		if rightInt, ok := right.(*object.Integer); ok {
+			leftVal, rightVal := leftInt.Value, rightInt.Value
+
+			switch op {
+			case code.OpLessThan:
+				return boolean(leftVal < rightVal)
+			case code.OpGreaterThan:
+				return boolean(leftVal > rightVal)
+			case code.OpLessEqual:
+				return boolean(leftVal <= rightVal)
+			case code.OpGreaterEqual:
+				return boolean(leftVal >= rightVal)
+			case code.OpEqual:
+				return boolean(leftVal == rightVal)
+			case code.OpNotEqual:
+				return boolean(leftVal != rightVal)
+			case code.OpAdd:
+				if !evaluator.CheckedArithmetic {
+					return evaluator.Integer(leftVal + rightVal)
+				}
+			case code.OpSub:
+				if !evaluator.CheckedArithmetic {
+					return evaluator.Integer(leftVal - rightVal)
+				}
+			}
+		}
+	}
+
+	return evaluator.Infix(infixOperators[op], left, right)
+}
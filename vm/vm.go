@@ -0,0 +1,548 @@
+package vm
+
+import (
+	"bytes"
+	"jeff/ast"
+	"jeff/code"
+	"jeff/compiler"
+	"jeff/evaluator"
+	"jeff/object"
+)
+
+// StackSize is how many values the stack starts with room for. It grows as needed
+const StackSize = 2048
+
+// MaxFrames limits how deep function calls can go before the program fails with a stack overflow
+const MaxFrames = 1 << 20
+
+// The vm's modules. Kept apart from the evaluator's so each engine runs its own imports
+var modules = evaluator.NewModuleLoader()
+
+// EvalFile runs a program read from the file at path as the entry point of the vm,
+// like evaluator.EvalFile
+func EvalFile(path string, program *ast.Program, env *object.Environment) object.Object {
+	return modules.Run(path, program, env, Eval)
+}
+
+// Eval compiles the program and runs it with env as its globals.
+// It works the same as evaluator.Eval, so the two engines can be swapped
+func Eval(program *ast.Program, env *object.Environment) object.Object {
+	if err := evaluator.Resolve(program, env); err != nil {
+		return err
+	}
+
+	main, err := compiler.Compile(program)
+	if err, ok := err.(*compiler.Error); ok {
+		return &object.ERROR{Message: err.Message, Position: err.Position}
+	} else if err != nil {
+		return evaluator.NewError("%s", err)
+	}
+
+	return New(main, env).Run()
+}
+
+// frame is a call to a closure in progress
+type frame struct {
+	cl *object.Closure
+	// Offset of the next instruction to run
+	ip int
+	// Offset of the instruction being run, for errors
+	lastIP int
+	// Where the closure's locals start on the stack. The closure itself sits just below
+	base int
+	// The closure the caller called, when the frame has been reused by tail calls
+	origin *object.Closure
+	// Tail calls made in the frame, most recent last
+	tailCalls []object.Frame
+}
+
+// handler is a try block that errors jump to
+type handler struct {
+	// Offset of the catch block
+	target int
+	// Stack pointer and frame the try block was started in, put back when an error is caught
+	sp    int
+	frame int
+}
+
+type VM struct {
+	stack []object.Object
+	// Always points to the next free slot. The top of the stack is stack[sp-1]
+	sp int
+
+	frames   []frame
+	handlers []handler
+}
+
+// New creates a vm to run the top level of a program with env as its globals
+func New(main *object.CompiledFunction, env *object.Environment) *VM {
+	cl := &object.Closure{Fn: main, Globals: env}
+
+	return &VM{
+		stack:  make([]object.Object, StackSize),
+		frames: []frame{{cl: cl}},
+	}
+}
+
+// Run runs the program and returns the value of its last statement, or what it returned.
+// If the program fails the error is returned with the stack of calls it came out of
+func (vm *VM) Run() object.Object {
+	for {
+		fr := &vm.frames[len(vm.frames)-1]
+		fn := fr.cl.Fn
+		ins := fn.Instructions
+
+		// Only the top level runs off the end, functions always finish with OpReturnValue
+		if fr.ip >= len(ins) {
+			if vm.sp > 0 {
+				return vm.stack[vm.sp-1]
+			}
+			return nil
+		}
+
+		ip := fr.ip
+		op := code.Opcode(ins[ip])
+		fr.lastIP = ip
+		fr.ip += 1 + operandWidth[op]
+
+		var err *object.ERROR
+
+		switch op {
+		case code.OpConstant:
+			vm.push(fn.Constants[code.ReadUint16(ins[ip+1:])])
+		case code.OpPop:
+			vm.sp--
+		case code.OpDup:
+			vm.push(vm.stack[vm.sp-1])
+		case code.OpTrue:
+			vm.push(evaluator.RIGHT)
+		case code.OpFalse:
+			vm.push(evaluator.HUANG)
+		case code.OpNull:
+			vm.push(evaluator.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow, code.OpFloorDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan, code.OpGreaterEqual, code.OpLessEqual:
+			right := vm.stack[vm.sp-1]
+			left := vm.stack[vm.sp-2]
+			vm.sp -= 2
+			err = vm.pushResult(infix(op, left, right))
+
+		case code.OpMinus:
+			err = vm.pushResult(evaluator.Prefix("-", vm.pop()))
+		case code.OpBang:
+			err = vm.pushResult(evaluator.Prefix("!", vm.pop()))
+		case code.OpTruthy:
+			vm.stack[vm.sp-1] = boolean(evaluator.IsTruthy(vm.stack[vm.sp-1]))
+
+		case code.OpJump:
+			fr.ip = int(code.ReadUint16(ins[ip+1:]))
+		case code.OpJumpNotTruthy:
+			if !evaluator.IsTruthy(vm.pop()) {
+				fr.ip = int(code.ReadUint16(ins[ip+1:]))
+			}
+		case code.OpJumpNotNull:
+			if vm.stack[vm.sp-1] != evaluator.NULL {
+				fr.ip = int(code.ReadUint16(ins[ip+1:]))
+			} else {
+				vm.sp--
+			}
+		case code.OpJumpIfSet:
+			if vm.stack[fr.base+int(ins[ip+1])] != nil {
+				fr.ip = int(code.ReadUint16(ins[ip+2:]))
+			}
+
+		case code.OpGetGlobal:
+			name := vm.constantString(fn, ins[ip+1:])
+			if val, ok := fr.cl.Globals.Get(name); ok {
+				vm.push(val)
+			} else if builtin, ok := evaluator.Builtin(name); ok {
+				vm.push(builtin)
+			} else {
+				err = evaluator.NewError("identifier not found: " + name)
+			}
+		case code.OpDefineGlobal:
+			fr.cl.Globals.Set(vm.constantString(fn, ins[ip+1:]), vm.pop())
+		case code.OpSetGlobal:
+			name := vm.constantString(fn, ins[ip+1:])
+			if _, ok := fr.cl.Globals.Assign(name, vm.pop()); !ok {
+				err = evaluator.NewError("identifier not found: " + name)
+			}
+		case code.OpCatchGlobal:
+			name := vm.constantString(fn, ins[ip+1:])
+			hidden, _ := fr.cl.Globals.Get(name)
+			fr.cl.Globals.Set(name, vm.stack[vm.sp-1])
+			vm.stack[vm.sp-1] = hidden
+		case code.OpRestoreGlobal:
+			name := vm.constantString(fn, ins[ip+1:])
+			result := vm.pop()
+			if hidden := vm.pop(); hidden != nil {
+				fr.cl.Globals.Set(name, hidden)
+			} else {
+				fr.cl.Globals.Delete(name)
+			}
+			vm.push(result)
+
+		case code.OpGetLocal:
+			slot := int(ins[ip+1])
+			if val := vm.stack[fr.base+slot]; val != nil {
+				vm.push(val)
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.LocalNames[slot])
+			}
+		case code.OpDefineLocal:
+			vm.stack[fr.base+int(ins[ip+1])] = vm.pop()
+		case code.OpSetLocal:
+			slot := int(ins[ip+1])
+			if vm.stack[fr.base+slot] != nil {
+				vm.stack[fr.base+slot] = vm.pop()
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.LocalNames[slot])
+			}
+
+		case code.OpGetCell:
+			slot := int(ins[ip+1])
+			if val := vm.stack[fr.base+slot].(*object.Cell).Value; val != nil {
+				vm.push(val)
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.LocalNames[slot])
+			}
+		case code.OpDefineCell:
+			vm.stack[fr.base+int(ins[ip+1])].(*object.Cell).Value = vm.pop()
+		case code.OpSetCell:
+			slot := int(ins[ip+1])
+			if cell := vm.stack[fr.base+slot].(*object.Cell); cell.Value != nil {
+				cell.Value = vm.pop()
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.LocalNames[slot])
+			}
+		case code.OpNewCell:
+			vm.stack[fr.base+int(ins[ip+1])] = &object.Cell{}
+		case code.OpBox:
+			slot := fr.base + int(ins[ip+1])
+			vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+		case code.OpLocalCell:
+			vm.push(vm.stack[fr.base+int(ins[ip+1])])
+
+		case code.OpGetFree:
+			index := int(ins[ip+1])
+			if val := fr.cl.Free[index].Value; val != nil {
+				vm.push(val)
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.FreeNames[index])
+			}
+		case code.OpSetFree:
+			index := int(ins[ip+1])
+			if cell := fr.cl.Free[index]; cell.Value != nil {
+				cell.Value = vm.pop()
+			} else {
+				err = evaluator.NewError("identifier not found: " + fn.FreeNames[index])
+			}
+		case code.OpFreeCell:
+			vm.push(fr.cl.Free[ins[ip+1]])
+
+		case code.OpClosure:
+			compiled := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.CompiledFunction)
+			count := int(ins[ip+3])
+
+			free := make([]*object.Cell, count)
+			for i := 0; i < count; i++ {
+				free[i] = vm.stack[vm.sp-count+i].(*object.Cell)
+			}
+			vm.sp -= count
+
+			vm.push(&object.Closure{Fn: compiled, Free: free, Globals: fr.cl.Globals})
+
+		case code.OpCall:
+			err = vm.call(int(ins[ip+1]))
+
+		case code.OpTailCall:
+			err = vm.tailCall(int(ins[ip+1]))
+
+		case code.OpReturnValue:
+			val := vm.pop()
+
+			// Returning from the top level ends the program
+			if len(vm.frames) == 1 {
+				return val
+			}
+
+			// Drop the try blocks the return jumped out of
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame == len(vm.frames)-1 {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			}
+
+			vm.sp = fr.base - 1
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.push(val)
+
+		case code.OpArray:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			elements := make([]object.Object, count)
+			copy(elements, vm.stack[vm.sp-count:vm.sp])
+			vm.sp -= count
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			hash := buildHash(vm.stack[vm.sp-2*count : vm.sp])
+			vm.sp -= 2 * count
+			err = vm.pushResult(hash)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.Index(left, index))
+		case code.OpSlice:
+			var start, end object.Object
+			if ins[ip+1]&2 != 0 {
+				end = vm.pop()
+			}
+			if ins[ip+1]&1 != 0 {
+				start = vm.pop()
+			}
+			err = vm.pushResult(evaluator.Slice(vm.pop(), start, end))
+		case code.OpInterpolate:
+			count := int(code.ReadUint16(ins[ip+1:]))
+			var out bytes.Buffer
+			for _, part := range vm.stack[vm.sp-count : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= count
+			vm.push(&object.String{Value: out.String()})
+		case code.OpMember:
+			err = vm.pushResult(evaluator.Member(vm.pop(), vm.constantString(fn, ins[ip+1:])))
+		case code.OpSetField:
+			val := vm.pop()
+			left := vm.pop()
+			name := vm.constantString(fn, ins[ip+1:])
+			target := vm.constantString(fn, ins[ip+3:])
+			err = vm.pushResult(evaluator.SetField(left, name, val, target))
+		case code.OpStruct:
+			template := fn.Constants[code.ReadUint16(ins[ip+1:])].(*object.StructType)
+			vm.push(&object.StructType{Fields: template.Fields})
+		case code.OpName:
+			evaluator.Name(vm.stack[vm.sp-1], vm.constantString(fn, ins[ip+1:]), vm.constantString(fn, ins[ip+3:]))
+		case code.OpImport:
+			path := vm.constantString(fn, ins[ip+1:])
+			name := vm.constantString(fn, ins[ip+3:])
+			err = vm.pushResult(modules.Import(path, fn.Positions[ip].File, name, Eval))
+
+		case code.OpSetupTry:
+			vm.handlers = append(vm.handlers, handler{
+				target: int(code.ReadUint16(ins[ip+1:])),
+				sp:     vm.sp,
+				frame:  len(vm.frames) - 1,
+			})
+		case code.OpPopTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpFail:
+			err = evaluator.NewError("%s", vm.constantString(fn, ins[ip+1:]))
+		}
+
+		if err != nil && !vm.catch(err) {
+			return err
+		}
+	}
+}
+
+// call calls the function below the arguments on the stack with them
+func (vm *VM) call(count int) *object.ERROR {
+	callee := vm.stack[vm.sp-1-count]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, count)
+	case *object.Builtin:
+		result := callee.Fn(vm.stack[vm.sp-count : vm.sp]...)
+		vm.sp -= count + 1
+		return vm.pushResult(result)
+	case *object.StructType:
+		args := make([]object.Object, count)
+		copy(args, vm.stack[vm.sp-count:vm.sp])
+		vm.sp -= count + 1
+		return vm.pushResult(evaluator.NewStructInstance(callee, args))
+	default:
+		return evaluator.NewError("not a function: %s", callee)
+	}
+}
+
+// tailCall calls the function below the arguments on the stack in place of the current
+// frame, so tail recursion runs in constant space. Anything that can't reuse the frame is
+// called normally, leaving the following OpReturnValue to return its result
+func (vm *VM) tailCall(count int) *object.ERROR {
+	current := vm.frames[len(vm.frames)-1]
+
+	cl, ok := vm.stack[vm.sp-1-count].(*object.Closure)
+	if !ok || len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame == len(vm.frames)-1 {
+		return vm.call(count)
+	}
+
+	if evaluator.ArityError(cl.Name, count, cl.Fn.NumRequired, cl.Fn.NumParameters, cl.Fn.Variadic) != nil {
+		// The failed call takes the place of a tail call in the traceback, like in the evaluator
+		if len(current.tailCalls) == object.MaxTailCalls {
+			vm.frames[len(vm.frames)-1].tailCalls = current.tailCalls[1:]
+		}
+		return vm.call(count)
+	}
+
+	copy(vm.stack[current.base-1:], vm.stack[vm.sp-1-count:vm.sp])
+	vm.sp = current.base + count
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	if err := vm.callClosure(cl, count); err != nil {
+		return err
+	}
+
+	next := &vm.frames[len(vm.frames)-1]
+	next.origin = current.origin
+	if next.origin == nil {
+		next.origin = current.cl
+	}
+	next.tailCalls = append(current.tailCalls, object.Frame{Function: cl.Name, Position: current.cl.Fn.CallPositions[current.lastIP]})
+	if len(next.tailCalls) > object.MaxTailCalls {
+		next.tailCalls = next.tailCalls[1:]
+	}
+	return nil
+}
+
+// callClosure starts a new frame for the closure. The arguments become its first locals,
+// and the rest of its locals start out unset
+func (vm *VM) callClosure(cl *object.Closure, count int) *object.ERROR {
+	fn := cl.Fn
+
+	if err := evaluator.ArityError(cl.Name, count, fn.NumRequired, fn.NumParameters, fn.Variadic); err != nil {
+		// Like in the evaluator, the call is part of the traceback even though the function never ran
+		caller := &vm.frames[len(vm.frames)-1]
+		err.Position = caller.cl.Fn.Positions[caller.lastIP]
+		err.Stack = append(err.Stack, object.Frame{Function: cl.Name, Position: caller.cl.Fn.CallPositions[caller.lastIP]})
+		return err
+	}
+
+	if len(vm.frames) >= MaxFrames {
+		return evaluator.NewError("stack overflow")
+	}
+
+	base := vm.sp - count
+	vm.grow(base + fn.NumLocals)
+
+	var rest *object.Array
+	if fn.Variadic {
+		rest = &object.Array{Elements: []object.Object{}}
+		if count > fn.NumParameters {
+			rest.Elements = append(rest.Elements, vm.stack[base+fn.NumParameters:base+count]...)
+			count = fn.NumParameters
+		}
+	}
+
+	for i := base + count; i < base+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	if rest != nil {
+		vm.stack[base+fn.NumParameters] = rest
+	}
+
+	vm.sp = base + fn.NumLocals
+	vm.frames = append(vm.frames, frame{cl: cl, base: base})
+	return nil
+}
+
+// catch passes an error to the innermost try block, unwinding any calls in between.
+// Returns false if there isn't one, leaving the error with the stack of calls it came out of
+func (vm *VM) catch(err *object.ERROR) bool {
+	current := &vm.frames[len(vm.frames)-1]
+	if !err.Position.IsValid() {
+		err.Position = current.cl.Fn.Positions[current.lastIP]
+	}
+
+	bottom := 0
+	if len(vm.handlers) > 0 {
+		bottom = vm.handlers[len(vm.handlers)-1].frame
+	}
+
+	for len(vm.frames)-1 > bottom {
+		callee := vm.frames[len(vm.frames)-1]
+		caller := vm.frames[len(vm.frames)-2]
+		for i := len(callee.tailCalls) - 1; i >= 0; i-- {
+			err.Stack = append(err.Stack, callee.tailCalls[i])
+		}
+		origin := callee.cl
+		if callee.origin != nil {
+			origin = callee.origin
+		}
+		err.Stack = append(err.Stack, object.Frame{Function: origin.Name, Position: caller.cl.Fn.CallPositions[caller.lastIP]})
+		vm.frames = vm.frames[:len(vm.frames)-1]
+	}
+
+	if len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.sp = h.sp
+	vm.push(evaluator.ErrorToHash(err))
+	vm.frames[len(vm.frames)-1].ip = h.target
+	return true
+}
+
+func (vm *VM) push(obj object.Object) {
+	vm.grow(vm.sp + 1)
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+// pushResult pushes the result of an operation, unless it is an error which is returned instead
+func (vm *VM) pushResult(obj object.Object) *object.ERROR {
+	switch obj := obj.(type) {
+	case *object.ERROR:
+		return obj
+	case nil:
+		vm.push(evaluator.NULL)
+	default:
+		vm.push(obj)
+	}
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// grow makes sure the stack has room for size values
+func (vm *VM) grow(size int) {
+	for size > len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+}
+
+// constantString reads a constant index operand and returns the string constant it refers to
+func (vm *VM) constantString(fn *object.CompiledFunction, operand []byte) string {
+	return fn.Constants[code.ReadUint16(operand)].(*object.String).Value
+}
+
+// buildHash creates a hash from keys and values laid out key first, as on the stack
+func buildHash(items []object.Object) object.Object {
+	hash := object.NewHash()
+
+	for i := 0; i < len(items); i += 2 {
+		key, value := items[i], items[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return evaluator.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+func boolean(value bool) *object.Boolean {
+	if value {
+		return evaluator.RIGHT
+	}
+	return evaluator.HUANG
+}
@@ -0,0 +1,96 @@
+package vm
+
+import (
+	"jeff/lexer"
+	"jeff/object"
+	"jeff/parser"
+	"testing"
+)
+
+// The evaluator's tests run against the vm as well, these cover what is particular to the vm
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"jeff's counter is fn() { jeff's n is 0; fn() { n is n + 1 } }; jeff's c is counter(); c(); c()", 2},
+		// Closures share the variable, not its value when they were created
+		{`jeff's f is fn() {
+			jeff's fs is [];
+			jeff's i is 0;
+			while (i < 3) { jeff's v is i; fs is push(fs, fn() { v }); i is i + 1 };
+			fs[0]()
+		}; f()`, 2},
+		{"jeff's f is fn() { jeff's g is fn() { h() }; jeff's h is fn() { 7 }; g() }; f()", 7},
+		{"jeff's f is fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"jeff's f is fn(a, b is fn() { a }) { a is 5; b() }; f(1)", 5},
+		{"jeff's x is 1; jeff's f is fn() { jeff's y is x; jeff's x is 10; y + x }; f()", 11},
+	}
+
+	for _, testCase := range tests {
+		testIntegerObject(t, testRun(testCase.input, object.NewEnvironment()), testCase.expected)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"jeff's f is fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)", 100000},
+		{"jeff's f is fn() { 1 + f() }; f()", "stack overflow"},
+		{"jeff's f is fn() { try { return 1 } catch (e) { 2 } }; f(); 1 + huang", "type mismatch: INTEGER + BOOLEAN"},
+		{"jeff's f is fn() { 1 + huang }; jeff's g is fn() { f() }; try { g() } catch (e) { 3 }", 3},
+		{"jeff's f is fn() { while (right) { try { break } catch (e) { 1 } }; raise(\"after\") }; try { f() } catch (e) { 4 }", 4},
+	}
+
+	for _, testCase := range tests {
+		evaluated := testRun(testCase.input, object.NewEnvironment())
+
+		switch expected := testCase.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			err, ok := evaluated.(*object.ERROR)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, err.Message)
+			}
+		}
+	}
+}
+
+func TestGlobalsPersist(t *testing.T) {
+	env := object.NewEnvironment()
+
+	testRun("jeff's x is 1; jeff's add is fn(y) { x + y }", env)
+	testRun("x is 10", env)
+	testIntegerObject(t, testRun("add(5)", env), 15)
+}
+
+func testRun(input string, env *object.Environment) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return Eval(p.ParseProgram(), env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object %T (%+v) is not integer", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. Expected %d, but got %d", expected, result.Value)
+		return false
+	}
+
+	return true
+}